@@ -1,49 +1,137 @@
 package aliasing
 
 import (
-	"bufio"
 	"context"
-	"errors"
-	"fmt"
-	"os"
-	"path"
 	"slices"
 	"strings"
 
-	"github.com/dangermike/roku_toy/logging"
-	"go.uber.org/zap"
+	"github.com/dangermike/roku_toy/config"
+	"github.com/dangermike/roku_toy/roku"
 )
 
+// Alias names a device either by USN, in which case it is found through
+// discovery, or by a static Address (IP, host name or URL) that is contacted
+// directly. An alias with a Group names an SSDP device group instead of a
+// single device. Room, Tags and Notes describe the device for people and
+// for selecting devices with --tag.
 type Alias struct {
-	USN  string
-	Name string
+	USN     string
+	Name    string
+	Address string
+	Group   string
+
+	Room  string
+	Tags  []string
+	Notes string
+}
+
+// HasTags reports whether a has every one of tags, ignoring case.
+func (a Alias) HasTags(tags ...string) bool {
+	for _, t := range tags {
+		if !slices.ContainsFunc(a.Tags, func(have string) bool { return strings.EqualFold(have, t) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// Tagged returns the device aliases that have every one of tags.
+func Tagged(aliases []Alias, tags ...string) []Alias {
+	var tagged []Alias
+	for _, a := range aliases {
+		if a.Group == "" && a.HasTags(tags...) {
+			tagged = append(tagged, a)
+		}
+	}
+	return tagged
+}
+
+// Target is the USN or, for static aliases, the address. Group aliases are
+// "group:" followed by the group ID.
+func (a Alias) Target() string {
+	if a.Group != "" {
+		return "group:" + a.Group
+	}
+	if a.Address != "" {
+		return a.Address
+	}
+	return a.USN
+}
+
+// GroupID returns the device group named by name, which may be the name of
+// a group alias or a group ID.
+func GroupID(aliases []Alias, name string) string {
+	for _, a := range aliases {
+		if a.Group != "" && a.Name == name {
+			return a.Group
+		}
+	}
+	return name
+}
+
+// GroupName returns the alias of a device group, or the ID if it has none.
+func GroupName(aliases []Alias, id string) string {
+	for _, a := range aliases {
+		if a.Group == id {
+			return a.Name
+		}
+	}
+	return id
+}
+
+// ForDevice returns the alias of the device with usn at location, which is
+// either aliased by USN or by an address that resolves to location.
+func ForDevice(aliases []Alias, usn, location string) (Alias, bool) {
+	for _, a := range aliases {
+		if a.USN != "" && a.USN == usn {
+			return a, true
+		}
+		if a.Address != "" {
+			if loc, err := roku.ParseAddress(a.Address); err == nil && loc.String() == location {
+				return a, true
+			}
+		}
+	}
+	return Alias{}, false
 }
 
+// Load returns the aliases from the config file.
 func Load(ctx context.Context) ([]Alias, error) {
-	log := logging.FromContext(ctx)
-	home, err := os.UserHomeDir()
+	cfg, err := config.Load(ctx)
 	if err != nil {
 		return nil, err
 	}
-	targetPath := path.Join(home, ".config", "roku_toy", "aliases")
+	return FromConfig(cfg), nil
+}
 
-	f, err := os.Open(targetPath)
-	if err != nil {
-		log.Debug("failed to open aliases file", zap.String("path", targetPath), zap.Error(err))
-		return nil, nil
+// FromConfig returns the aliases in cfg.
+func FromConfig(cfg *config.Config) []Alias {
+	aliases := make([]Alias, 0, len(cfg.Aliases))
+	for _, e := range cfg.Aliases {
+		aliases = append(aliases, Alias{
+			USN:     e.USN,
+			Name:    e.Name,
+			Address: e.Address,
+			Group:   e.Group,
+			Room:    e.Room,
+			Tags:    e.Tags,
+			Notes:   e.Notes,
+		})
 	}
-	defer f.Close()
-	scn := bufio.NewScanner(f)
-	var retval []Alias
-	for scn.Scan() {
-		if len(scn.Text()) == 0 {
-			continue
+	return aliases
+}
+
+// Replaced returns the aliases that adding a would drop because they share
+// its name or target.
+func Replaced(aliases []Alias, a Alias) []Alias {
+	var replaced []Alias
+	for _, old := range aliases {
+		same := old.Name == a.Name && old.Target() == a.Target()
+		if !same && (old.Name == a.Name || old.Target() == a.Target()) {
+			replaced = append(replaced, old)
 		}
-		kv := strings.SplitN(scn.Text(), ",", 2)
-		retval = append(retval, Alias{kv[0], kv[1]})
 	}
-
-	return retval, nil
+	return replaced
 }
 
 func uniqueify(aliases []Alias) []Alias {
@@ -53,7 +141,7 @@ func uniqueify(aliases []Alias) []Alias {
 	var dropped int
 
 	for i := 0; i < len(aliases); i++ {
-		if _, ok := seenUSN[aliases[i].USN]; ok {
+		if _, ok := seenUSN[aliases[i].Target()]; ok {
 			dropped++
 			continue
 		}
@@ -61,7 +149,7 @@ func uniqueify(aliases []Alias) []Alias {
 			dropped++
 			continue
 		}
-		seenUSN[aliases[i].USN] = struct{}{}
+		seenUSN[aliases[i].Target()] = struct{}{}
 		seenName[aliases[i].Name] = struct{}{}
 		if dropped > 0 {
 			aliases[i-dropped] = aliases[i]
@@ -72,42 +160,36 @@ func uniqueify(aliases []Alias) []Alias {
 	return aliases
 }
 
+// Save replaces the aliases in the config file. When two aliases share a
+// name or target, the later one wins.
 func Save(ctx context.Context, aliases []Alias) error {
-	log := logging.FromContext(ctx)
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return err
-	}
-	if _, err := os.Stat(path.Join(home, ".config", "roku_toy")); os.IsNotExist(err) {
-		if err := os.MkdirAll(path.Join(home, ".config", "roku_toy"), 0o755); err != nil {
-			return err
-		}
-	} else if err != nil {
-		return err
-	}
-
-	targetPath := path.Join(home, ".config", "roku_toy", "aliases")
+	return Update(ctx, func([]Alias) ([]Alias, error) {
+		return aliases, nil
+	})
+}
 
-	f, err := os.Create(targetPath)
-	if err != nil {
-		log.Debug("failed to open aliases file", zap.String("path", targetPath), zap.Error(err))
-		return nil
-	}
-	defer f.Close()
-	for _, alias := range uniqueify(aliases) {
-		if err := errors.Join(
-			errOnly(fmt.Fprint(f, alias.USN)),
-			errOnly(fmt.Fprint(f, ",")),
-			errOnly(fmt.Fprint(f, alias.Name)),
-			errOnly(fmt.Fprint(f, "\n")),
-		); err != nil {
+// Update replaces the aliases in the config file with the result of fn,
+// which is given the current ones. The config is locked while fn runs, so
+// concurrent updates don't lose each other's changes. When two aliases share
+// a name or target, the later one wins. Settings kept with an alias in the
+// config but not in Alias, such as its shortcuts, stay with its name.
+func Update(ctx context.Context, fn func([]Alias) ([]Alias, error)) error {
+	return config.Update(ctx, func(cfg *config.Config) error {
+		aliases, err := fn(FromConfig(cfg))
+		if err != nil {
 			return err
 		}
-	}
-
-	return f.Close()
-}
-
-func errOnly(cnt int, err error) error {
-	return err
+		byName := map[string]config.AliasEntry{}
+		for _, e := range cfg.Aliases {
+			byName[e.Name] = e
+		}
+		cfg.Aliases = nil
+		for _, a := range uniqueify(aliases) {
+			e := byName[a.Name]
+			e.Name, e.USN, e.Address, e.Group = a.Name, a.USN, a.Address, a.Group
+			e.Room, e.Tags, e.Notes = a.Room, a.Tags, a.Notes
+			cfg.Aliases = append(cfg.Aliases, e)
+		}
+		return nil
+	})
 }
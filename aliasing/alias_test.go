@@ -1,10 +1,15 @@
 package aliasing
 
 import (
+	"context"
+	"path/filepath"
 	"strconv"
 	"testing"
 
+	"github.com/dangermike/roku_toy/config"
+	"github.com/dangermike/roku_toy/logging"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
 )
 
 func TestUniqueify(t *testing.T) {
@@ -14,16 +19,24 @@ func TestUniqueify(t *testing.T) {
 	}{
 		{},
 		{
-			src: []Alias{{"u_a", "n_a"}, {"u_b", "n_b"}},
-			exp: []Alias{{"u_a", "n_a"}, {"u_b", "n_b"}},
+			src: []Alias{{USN: "u_a", Name: "n_a"}, {USN: "u_b", Name: "n_b"}},
+			exp: []Alias{{USN: "u_a", Name: "n_a"}, {USN: "u_b", Name: "n_b"}},
 		},
 		{
-			src: []Alias{{"u_a", "n_a"}, {"u_a", "n_c"}, {"u_b", "n_b"}, {"u_b", "n_d"}},
-			exp: []Alias{{"u_a", "n_c"}, {"u_b", "n_d"}},
+			src: []Alias{{USN: "u_a", Name: "n_a"}, {USN: "u_a", Name: "n_c"}, {USN: "u_b", Name: "n_b"}, {USN: "u_b", Name: "n_d"}},
+			exp: []Alias{{USN: "u_a", Name: "n_c"}, {USN: "u_b", Name: "n_d"}},
 		},
 		{
-			src: []Alias{{"u_a", "n_a"}, {"u_a", "n_c"}, {"u_b", "n_b"}, {"u_b", "n_c"}},
-			exp: []Alias{{"u_a", "n_a"}, {"u_b", "n_c"}},
+			src: []Alias{{USN: "u_a", Name: "n_a"}, {USN: "u_a", Name: "n_c"}, {USN: "u_b", Name: "n_b"}, {USN: "u_b", Name: "n_c"}},
+			exp: []Alias{{USN: "u_a", Name: "n_a"}, {USN: "u_b", Name: "n_c"}},
+		},
+		{
+			src: []Alias{{USN: "u_a", Name: "n_a"}, {Address: "10.0.0.1", Name: "n_b"}, {Address: "10.0.0.2", Name: "n_c"}, {Address: "10.0.0.1", Name: "n_d"}},
+			exp: []Alias{{USN: "u_a", Name: "n_a"}, {Address: "10.0.0.2", Name: "n_c"}, {Address: "10.0.0.1", Name: "n_d"}},
+		},
+		{
+			src: []Alias{{USN: "g_a", Name: "n_a"}, {Group: "g_a", Name: "n_b"}, {Group: "g_a", Name: "n_c"}},
+			exp: []Alias{{USN: "g_a", Name: "n_a"}, {Group: "g_a", Name: "n_c"}},
 		},
 	} {
 		t.Run(strconv.Itoa(i), func(t *testing.T) {
@@ -32,3 +45,59 @@ func TestUniqueify(t *testing.T) {
 		})
 	}
 }
+
+func TestGroupID(t *testing.T) {
+	aliases := []Alias{{USN: "u_a", Name: "den"}, {Group: "g_1", Name: "office"}}
+	require.Equal(t, "g_1", GroupID(aliases, "office"))
+	require.Equal(t, "g_2", GroupID(aliases, "g_2"))
+	require.Equal(t, "den", GroupID(aliases, "den"), "device aliases are not groups")
+	require.Equal(t, "office", GroupName(aliases, "g_1"))
+	require.Equal(t, "g_2", GroupName(aliases, "g_2"))
+}
+
+func TestReplaced(t *testing.T) {
+	aliases := []Alias{{USN: "u_a", Name: "den"}, {USN: "u_b", Name: "office"}, {Address: "10.0.0.1", Name: "garage"}}
+	require.Empty(t, Replaced(aliases, Alias{USN: "u_c", Name: "kitchen"}))
+	require.Empty(t, Replaced(aliases, Alias{USN: "u_a", Name: "den"}), "re-adding an alias replaces nothing")
+	require.Equal(t, []Alias{{USN: "u_a", Name: "den"}, {USN: "u_b", Name: "office"}}, Replaced(aliases, Alias{USN: "u_b", Name: "den"}))
+	require.Equal(t, []Alias{{Address: "10.0.0.1", Name: "garage"}}, Replaced(aliases, Alias{Address: "10.0.0.1", Name: "shed"}))
+}
+
+func TestUpdateKeepsSettings(t *testing.T) {
+	ctx := logging.NewContext(context.Background(), zap.NewNop())
+	config.SetPath(filepath.Join(t.TempDir(), "config.yaml"))
+	defer config.SetPath("")
+
+	require.NoError(t, config.Save(ctx, &config.Config{Aliases: []config.AliasEntry{
+		{Name: "den", USN: "A", Shortcuts: []config.Shortcut{{Name: "news", App: "12"}}},
+	}}))
+	require.NoError(t, Update(ctx, func(aliases []Alias) ([]Alias, error) {
+		return append(aliases, Alias{Name: "den", Address: "10.0.0.1"}, Alias{Name: "office", USN: "B"}), nil
+	}))
+
+	cfg, err := config.Load(ctx)
+	require.NoError(t, err)
+	require.Len(t, cfg.Aliases, 2)
+	require.Equal(t, "10.0.0.1", cfg.Aliases[0].Address)
+	require.Equal(t, "news", cfg.Aliases[0].Shortcuts[0].Name, "the shortcuts follow the name")
+	require.Empty(t, cfg.Aliases[1].Shortcuts)
+}
+
+func TestTagged(t *testing.T) {
+	aliases := []Alias{
+		{USN: "u_a", Name: "den", Tags: []string{"night", "Kids"}},
+		{USN: "u_b", Name: "office", Tags: []string{"night"}},
+		{Group: "g_1", Name: "upstairs", Tags: []string{"night"}},
+		{Address: "10.0.0.1", Name: "garage"},
+	}
+	names := func(aliases []Alias) []string {
+		var names []string
+		for _, a := range aliases {
+			names = append(names, a.Name)
+		}
+		return names
+	}
+	require.Equal(t, []string{"den", "office"}, names(Tagged(aliases, "night")), "group aliases are never tagged devices")
+	require.Equal(t, []string{"den"}, names(Tagged(aliases, "NIGHT", "kids")))
+	require.Empty(t, Tagged(aliases, "bedroom"))
+}
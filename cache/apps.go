@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"html"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dangermike/roku_toy/logging"
+	"github.com/dangermike/roku_toy/roku"
+	"github.com/dangermike/roku_toy/xdg"
+	"go.uber.org/zap"
+)
+
+// Apps are the channels installed on a device, kept so channel names can be
+// completed without asking the device.
+type Apps struct {
+	USN     string    `json:"usn"`
+	Apps    []App     `json:"apps"`
+	Updated time.Time `json:"updated"`
+}
+
+type App struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// appsMu serializes RecordApps within the process, as commands run against
+// several devices at once.
+var appsMu sync.Mutex
+
+func appsPath() (string, error) {
+	dir, err := xdg.CacheHome()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "apps.json"), nil
+}
+
+// LoadApps returns the cached app lists. Like Load, a missing or unreadable
+// cache is empty.
+func LoadApps(ctx context.Context) ([]Apps, error) {
+	log := logging.FromContext(ctx)
+	targetPath, err := appsPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(targetPath)
+	if err != nil {
+		log.Debug("failed to open app cache", zap.String("path", targetPath), zap.Error(err))
+		return nil, nil
+	}
+	var entries []Apps
+	if err := json.Unmarshal(data, &entries); err != nil {
+		log.Debug("ignoring corrupt app cache", zap.String("path", targetPath), zap.Error(err))
+		return nil, nil
+	}
+	return entries, nil
+}
+
+// FindApps returns the app list cached for usn, if any.
+func FindApps(entries []Apps, usn string) (Apps, bool) {
+	i := slices.IndexFunc(entries, func(e Apps) bool { return e.USN == usn })
+	if i < 0 {
+		return Apps{}, false
+	}
+	return entries[i], true
+}
+
+// RecordApps replaces the app list cached for usn and returns the new entry.
+func RecordApps(ctx context.Context, usn string, apps []roku.App, now time.Time) (Apps, error) {
+	appsMu.Lock()
+	defer appsMu.Unlock()
+
+	entries, err := LoadApps(ctx)
+	if err != nil {
+		return Apps{}, err
+	}
+	e := Apps{USN: usn, Updated: now}
+	for _, app := range apps {
+		e.Apps = append(e.Apps, App{ID: app.ID, Name: html.UnescapeString(app.Name)})
+	}
+	if i := slices.IndexFunc(entries, func(o Apps) bool { return o.USN == usn }); i >= 0 {
+		entries[i] = e
+	} else {
+		entries = append(entries, e)
+	}
+	slices.SortFunc(entries, func(a, b Apps) int { return strings.Compare(a.USN, b.USN) })
+
+	targetPath, err := appsPath()
+	if err != nil {
+		return e, err
+	}
+	return e, writeJSON(targetPath, entries)
+}
@@ -0,0 +1,163 @@
+// Package cache remembers where devices were last found so commands can skip
+// the SSDP wait, and which apps they have so channel names can be completed.
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/dangermike/roku_toy/logging"
+	"github.com/dangermike/roku_toy/roku"
+	"github.com/dangermike/roku_toy/xdg"
+	"go.uber.org/zap"
+)
+
+// DefaultTTL is used for devices that did not report a Cache-Control max-age.
+const DefaultTTL = time.Hour
+
+type Entry struct {
+	USN               string        `json:"usn"`
+	Location          string        `json:"location"`
+	DeviceGroup       string        `json:"device_group,omitempty"`
+	BroadcastInterval time.Duration `json:"broadcast_interval"`
+	LastSeen          time.Time     `json:"last_seen"`
+}
+
+// Fresh reports whether the entry is still within the max-age the device
+// announced when it was last seen.
+func (e Entry) Fresh(now time.Time) bool {
+	ttl := e.BroadcastInterval
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return now.Before(e.LastSeen.Add(ttl))
+}
+
+func (e Entry) Device() (*roku.Device, error) {
+	loc, err := url.Parse(e.Location)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse cached location '%s': %w", e.Location, err)
+	}
+	return &roku.Device{
+		Location:          loc,
+		USN:               e.USN,
+		DeviceGroup:       e.DeviceGroup,
+		BroadcastInterval: e.BroadcastInterval,
+	}, nil
+}
+
+func filePath() (string, error) {
+	dir, err := xdg.CacheHome()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "devices.json"), nil
+}
+
+// Load returns the cached devices. A missing or unreadable cache is empty,
+// not an error.
+func Load(ctx context.Context) ([]Entry, error) {
+	log := logging.FromContext(ctx)
+	targetPath, err := filePath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(targetPath)
+	if err != nil {
+		log.Debug("failed to open device cache", zap.String("path", targetPath), zap.Error(err))
+		return nil, nil
+	}
+	var entries []Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		log.Debug("ignoring corrupt device cache", zap.String("path", targetPath), zap.Error(err))
+		return nil, nil
+	}
+	return entries, nil
+}
+
+func Save(ctx context.Context, entries []Entry) error {
+	targetPath, err := filePath()
+	if err != nil {
+		return err
+	}
+	return writeJSON(targetPath, entries)
+}
+
+// writeJSON replaces the file at targetPath with v by writing a temporary file
+// next to it and renaming it into place, so concurrent readers never see a
+// partial file.
+func writeJSON(targetPath string, v any) error {
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(targetPath), "."+filepath.Base(targetPath)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", targetPath, err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %w", targetPath, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", targetPath, err)
+	}
+	if err := os.Rename(f.Name(), targetPath); err != nil {
+		return fmt.Errorf("failed to write %s: %w", targetPath, err)
+	}
+	return nil
+}
+
+// Find returns the entry for usn, if any.
+func Find(entries []Entry, usn string) (Entry, bool) {
+	i := slices.IndexFunc(entries, func(e Entry) bool { return e.USN == usn })
+	if i < 0 {
+		return Entry{}, false
+	}
+	return entries[i], true
+}
+
+// Update adds or replaces the entry for dev.
+func Update(entries []Entry, dev *roku.Device, now time.Time) []Entry {
+	e := Entry{
+		USN:               dev.USN,
+		Location:          dev.Location.String(),
+		DeviceGroup:       dev.DeviceGroup,
+		BroadcastInterval: dev.BroadcastInterval,
+		LastSeen:          now,
+	}
+	if i := slices.IndexFunc(entries, func(o Entry) bool { return o.USN == dev.USN }); i >= 0 {
+		entries[i] = e
+		return entries
+	}
+	return append(entries, e)
+}
+
+// Verify checks that the device at the cached location is still the one with
+// the cached USN.
+func Verify(ctx context.Context, e Entry, timeout time.Duration) (*roku.Device, error) {
+	dev, err := e.Device()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	info, err := dev.QueryDeviceInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if info.SerialNumber != e.USN {
+		return nil, fmt.Errorf("device at %s is now %s, not %s", e.Location, info.SerialNumber, e.USN)
+	}
+	return dev, nil
+}
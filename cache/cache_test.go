@@ -0,0 +1,105 @@
+package cache
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dangermike/roku_toy/logging"
+	"github.com/dangermike/roku_toy/roku"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestUpdate(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	loc, _ := url.Parse("http://10.0.0.1:8060/")
+	loc2, _ := url.Parse("http://10.0.0.2:8060/")
+
+	entries := Update(nil, &roku.Device{USN: "A", Location: loc, BroadcastInterval: time.Minute}, now)
+	entries = Update(entries, &roku.Device{USN: "B", Location: loc, DeviceGroup: "g"}, now)
+	entries = Update(entries, &roku.Device{USN: "A", Location: loc2, BroadcastInterval: time.Minute}, now.Add(time.Second))
+	require.Len(t, entries, 2)
+
+	a, ok := Find(entries, "A")
+	require.True(t, ok)
+	require.Equal(t, "http://10.0.0.2:8060/", a.Location)
+	require.True(t, a.Fresh(now.Add(time.Minute)))
+	require.False(t, a.Fresh(now.Add(time.Minute+time.Second)))
+
+	b, ok := Find(entries, "B")
+	require.True(t, ok)
+	require.True(t, b.Fresh(now.Add(DefaultTTL-time.Second)), "no max-age falls back to the default")
+
+	_, ok = Find(entries, "C")
+	require.False(t, ok)
+}
+
+func TestSaveLoad(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	ctx := logging.NewContext(context.Background(), zap.NewNop())
+
+	entries, err := Load(ctx)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	exp := []Entry{{USN: "A", Location: "http://10.0.0.1:8060/", BroadcastInterval: time.Hour, LastSeen: time.Unix(1700000000, 0).UTC()}}
+	require.NoError(t, Save(ctx, exp))
+	entries, err = Load(ctx)
+	require.NoError(t, err)
+	require.Equal(t, exp, entries)
+
+	p, err := filePath()
+	require.NoError(t, err)
+	files, err := os.ReadDir(filepath.Dir(p))
+	require.NoError(t, err)
+	require.Len(t, files, 1, "no temporary files are left behind")
+}
+
+func TestVerify(t *testing.T) {
+	ctx := logging.NewContext(context.Background(), zap.NewNop())
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/query/device-info", r.URL.Path)
+		_, _ = w.Write([]byte(`<?xml version="1.0" encoding="UTF-8" ?>
+<device-info>
+	<serial-number>ABCDEFGHIJKL</serial-number>
+	<model-name>Roku Ultra</model-name>
+</device-info>`))
+	}))
+	defer srv.Close()
+
+	dev, err := Verify(ctx, Entry{USN: "ABCDEFGHIJKL", Location: srv.URL + "/"}, time.Second)
+	require.NoError(t, err)
+	require.Equal(t, "ABCDEFGHIJKL", dev.USN)
+
+	_, err = Verify(ctx, Entry{USN: "ZYXWVUTSRQPO", Location: srv.URL + "/"}, time.Second)
+	require.Error(t, err)
+}
+
+func TestRecordApps(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	ctx := logging.NewContext(context.Background(), zap.NewNop())
+	now := time.Unix(1700000000, 0).UTC()
+
+	_, err := RecordApps(ctx, "B", []roku.App{{ID: "12", Name: "Netflix"}}, now)
+	require.NoError(t, err)
+	_, err = RecordApps(ctx, "A", []roku.App{{ID: "13", Name: "Plex - Free Movies &amp; TV"}}, now)
+	require.NoError(t, err)
+	_, err = RecordApps(ctx, "B", []roku.App{{ID: "837", Name: "YouTube"}}, now)
+	require.NoError(t, err)
+
+	entries, err := LoadApps(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []Apps{
+		{USN: "A", Apps: []App{{ID: "13", Name: "Plex - Free Movies & TV"}}, Updated: now},
+		{USN: "B", Apps: []App{{ID: "837", Name: "YouTube"}}, Updated: now},
+	}, entries)
+	_, ok := FindApps(entries, "C")
+	require.False(t, ok)
+}
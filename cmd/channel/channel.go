@@ -4,10 +4,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/dangermike/roku_toy/aliasing"
+	"github.com/dangermike/roku_toy/config"
+	"github.com/dangermike/roku_toy/discovery"
 	"github.com/dangermike/roku_toy/logging"
+	"github.com/dangermike/roku_toy/output"
 	"github.com/dangermike/roku_toy/roku"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
@@ -20,7 +26,7 @@ func Cmd() *cobra.Command {
 		Short: "get or set channel",
 	}
 
-	cmd.AddCommand(cmdSet(), cmdGet(), cmdList())
+	cmd.AddCommand(cmdSet(), cmdGet(), cmdList(), cmdFavorites())
 
 	return cmd
 }
@@ -28,10 +34,15 @@ func Cmd() *cobra.Command {
 func cmdSet() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "set",
-		Short: "Set the current channel by name or number",
-		RunE:  setE,
+		Short: "Set the current channel by shortcut, name or number",
+		Long: `The channel is looked up among the shortcuts in the config file first,
+then by fuzzy matching against the names of the installed apps, and is
+otherwise taken to be an app ID.`,
+		RunE:              setE,
+		ValidArgsFunction: completeChannels,
 	}
-	AddFlags(cmd.Flags())
+	AddDiscoveryFlags(cmd.Flags())
+	AddFanOutFlags(cmd.Flags())
 	return cmd
 }
 
@@ -41,7 +52,8 @@ func cmdGet() *cobra.Command {
 		Short: "Get the current channel",
 		RunE:  getE,
 	}
-	AddFlags(cmd.Flags())
+	AddDiscoveryFlags(cmd.Flags())
+	AddFanOutFlags(cmd.Flags())
 	return cmd
 }
 
@@ -51,130 +63,288 @@ func cmdList() *cobra.Command {
 		Short: "List installed channels",
 		RunE:  listE,
 	}
-	AddFlags(cmd.Flags())
+	AddDiscoveryFlags(cmd.Flags())
+	AddFanOutFlags(cmd.Flags())
 	return cmd
 }
 
 func setE(cmd *cobra.Command, args []string) error {
-	cfg, err := ParseFlags(cmd.Flags())
-	if err != nil {
-		return nil
-	}
 	if len(args) != 1 {
 		return errors.New("channel name or ID required")
 	}
-	ctx := logging.NewContext(cmd.Context(), logging.Configure(cfg.Debug))
-	device, err := GetDevice(ctx, cfg.Device, cfg.FirstDevice)
+	ctx := cmd.Context()
+	cfg := FromContext(ctx)
+	conf, err := config.Load(ctx)
 	if err != nil {
 		return err
 	}
+	al := aliasing.FromConfig(conf)
+	return ForEach(ctx, cfg, func(ctx context.Context, device *roku.Device) (any, error) {
+		if s, ok := conf.Shortcut(aliasOf(al, device), args[0]); ok {
+			return nil, launchShortcut(ctx, device, s)
+		}
+		return nil, setChannel(ctx, device, args[0])
+	})
+}
 
-	if _, err := strconv.Atoi(args[0]); err == nil {
-		return device.Launch(ctx, args[0])
+func setChannel(ctx context.Context, device *roku.Device, channel string) error {
+	if _, err := strconv.Atoi(channel); err == nil {
+		return device.Launch(ctx, channel)
+	}
+	if strings.EqualFold(channel, "home") {
+		return device.Home(ctx)
 	}
 
-	err = device.LaunchByName(ctx, args[0])
+	apps, err := device.QueryApps(ctx)
+	if err != nil {
+		return err
+	}
+	recordApps(ctx, device, apps)
+	// an exact name, as the shell completes it, wins over fuzzy matches
+	if app := appNamed(apps, channel); app != nil {
+		return device.Launch(ctx, app.ID)
+	}
+	device.SetApps(apps)
+	err = device.LaunchByName(ctx, channel)
 
-	if errors.Is(err, roku.ErrApplicationNotFound(args[0])) {
-		if lerr := device.Launch(ctx, args[0]); lerr != nil {
+	if errors.Is(err, roku.ErrApplicationNotFound(channel)) {
+		if lerr := device.Launch(ctx, channel); lerr != nil {
 			return err
 		}
+		return nil
 	}
 
 	return err
 }
 
 func getE(cmd *cobra.Command, args []string) error {
-	cfg, err := ParseFlags(cmd.Flags())
-	if err != nil {
-		return nil
-	}
-	ctx := logging.NewContext(cmd.Context(), logging.Configure(cfg.Debug))
-	device, err := GetDevice(ctx, cfg.Device, cfg.FirstDevice)
-	if err != nil {
-		return err
-	}
-
-	app, err := device.ActiveApp(ctx)
-	if err != nil {
-		return nil
-	}
-	fmt.Printf("%s (%s)\n", app.Name, app.ID)
-	return nil
+	ctx := cmd.Context()
+	cfg := FromContext(ctx)
+	return ForEach(ctx, cfg, func(ctx context.Context, device *roku.Device) (any, error) {
+		app, err := device.ActiveApp(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return output.NewApp(app), nil
+	})
 }
 
 func listE(cmd *cobra.Command, args []string) error {
-	cfg, err := ParseFlags(cmd.Flags())
-	if err != nil {
-		return nil
-	}
-	ctx := logging.NewContext(cmd.Context(), logging.Configure(cfg.Debug))
-	device, err := GetDevice(ctx, cfg.Device, cfg.FirstDevice)
-	if err != nil {
-		return err
-	}
-
-	apps, err := device.QueryApps(ctx)
-	if err != nil {
-		return err
-	}
+	ctx := cmd.Context()
+	cfg := FromContext(ctx)
+	return ForEach(ctx, cfg, func(ctx context.Context, device *roku.Device) (any, error) {
+		apps, err := device.QueryApps(ctx)
+		if err != nil {
+			return nil, err
+		}
+		recordApps(ctx, device, apps)
 
-	fmt.Printf("%s (%d)\n", "home", 0)
-	for _, app := range apps {
-		fmt.Printf("%s (%s)\n", app.Name, app.ID)
-	}
-	return nil
+		records := []output.App{{ID: "0", Name: "home"}}
+		for _, app := range apps {
+			records = append(records, output.NewApp(app))
+		}
+		return records, nil
+	})
 }
 
+// AddFlags adds the device selection and logging flags. The root command
+// adds them as persistent flags so every command has them.
 func AddFlags(flags *pflag.FlagSet) {
 	flags.BoolP("first", "1", false, "select device first device found on the network")
-	flags.StringP("device", "d", "", "select device by name or USN (required if more than one device on the network)")
+	flags.StringP("group", "g", "", "run against every device in this SSDP device group (ID or alias)")
+	flags.StringArray("tag", nil, "run against every aliased device with this tag (repeat to require several)")
+	flags.StringArrayP("device", "d", nil, "select device by name, USN, IP address, host name or URL (required if more than one device on the network; repeatable where a command can run on several)")
 	flags.BoolP("verbose", "v", false, "verbose logging")
 }
 
+// AddFanOutFlags adds the flags of commands that can run against many devices
+// at once through ForEach.
+func AddFanOutFlags(flags *pflag.FlagSet) {
+	flags.Bool("all", false, "run against every device found on the network")
+	flags.Bool("json", false, "print one JSON record per device")
+	_ = flags.MarkDeprecated("json", "use --output jsonl")
+}
+
+// AddDiscoveryFlags adds the flags that control how devices are found.
+func AddDiscoveryFlags(flags *pflag.FlagSet) {
+	flags.StringSlice("interface", nil, "only search on these network interfaces (glob patterns allowed)")
+	flags.StringSlice("exclude-interface", nil, "do not search on these network interfaces (glob patterns allowed)")
+	flags.Duration("discovery-timeout", 2*time.Second, "how long to wait for SSDP responses")
+	flags.Int("retransmits", 2, "how many times to repeat the SSDP search in case it is lost")
+	flags.Int("mx", 1, "seconds devices may wait before answering the SSDP search")
+	flags.Int("expect", 0, "stop the SSDP search as soon as this many devices have answered")
+	flags.String("ip", string(roku.IPv4Only), "address families to search: ipv4, ipv6, prefer-ipv4 or prefer-ipv6")
+	flags.Bool("no-cache", false, "ignore the cache of previously discovered devices")
+	flags.StringSlice("cidr", nil, "subnets for the scan backend (default: the subnets of the searched interfaces)")
+	flags.String("discovery", DefaultDiscovery, "discovery chain: backends (cache, ssdp, static, scan, listen) joined by '+' to merge or '|' to race, with ',' separating fallback stages")
+}
+
 type Cfg struct {
 	Debug       bool
-	Device      string
+	Devices     []string
+	Group       string
+	Tags        []string
+	All         bool
+	JSON        bool
+	Output      *output.Printer
 	FirstDevice bool
+	NoCache     bool
+	SSDP        roku.SSDPOptions
+	CIDRs       []string
+	Discovery   string
 }
 
+// ParseFlags reads the flags added by AddFlags, and those added by
+// AddDiscoveryFlags and AddFanOutFlags where the command has them.
 func ParseFlags(flags *pflag.FlagSet) (Cfg, error) {
 	var cfg Cfg
 
-	return cfg, errors.Join(
+	errs := []error{
 		GetFlagT(&cfg.Debug, flags, "verbose", (*pflag.FlagSet).GetBool),
 		GetFlagT(&cfg.FirstDevice, flags, "first", (*pflag.FlagSet).GetBool),
-		GetFlagT(&cfg.Device, flags, "device", (*pflag.FlagSet).GetString),
+		GetFlagT(&cfg.Devices, flags, "device", (*pflag.FlagSet).GetStringArray),
+		GetFlagT(&cfg.Group, flags, "group", (*pflag.FlagSet).GetString),
+		GetFlagT(&cfg.Tags, flags, "tag", (*pflag.FlagSet).GetStringArray),
+	}
+	if flags.Lookup("discovery") != nil {
+		errs = append(errs, ParseDiscoveryCfg(&cfg, flags))
+	}
+	if flags.Lookup("all") != nil {
+		errs = append(errs,
+			GetFlagT(&cfg.All, flags, "all", (*pflag.FlagSet).GetBool),
+			GetFlagT(&cfg.JSON, flags, "json", (*pflag.FlagSet).GetBool),
+		)
+	}
+	errs = append(errs, ParseOutput(&cfg, flags))
+	return cfg, errors.Join(errs...)
+}
+
+type cfgKey struct{}
+
+// NewContext returns a copy of ctx carrying cfg. The root command stores the
+// parsed flags this way for every command.
+func NewContext(ctx context.Context, cfg Cfg) context.Context {
+	return context.WithValue(ctx, cfgKey{}, cfg)
+}
+
+// FromContext returns the Cfg stored by NewContext, or an empty one.
+func FromContext(ctx context.Context) Cfg {
+	cfg, _ := ctx.Value(cfgKey{}).(Cfg)
+	return cfg
+}
+
+// ParseOutput sets cfg.Output from the root --output flag. --json, where
+// the command has it, means jsonl.
+func ParseOutput(cfg *Cfg, flags *pflag.FlagSet) error {
+	spec := output.Table
+	if flags != nil && flags.Lookup("output") != nil {
+		if err := GetFlagT(&spec, flags, "output", (*pflag.FlagSet).GetString); err != nil {
+			return err
+		}
+	}
+	if cfg.JSON {
+		spec = output.JSONL
+	}
+	var err error
+	cfg.Output, err = output.New(os.Stdout, spec)
+	return err
+}
+
+// ParseDiscoveryCfg fills in the parts of cfg set by AddDiscoveryFlags.
+func ParseDiscoveryCfg(cfg *Cfg, flags *pflag.FlagSet) error {
+	return errors.Join(
+		GetFlagT(&cfg.NoCache, flags, "no-cache", (*pflag.FlagSet).GetBool),
+		GetFlagT(&cfg.CIDRs, flags, "cidr", (*pflag.FlagSet).GetStringSlice),
+		GetFlagT(&cfg.Discovery, flags, "discovery", (*pflag.FlagSet).GetString),
+		ParseDiscoveryFlags(&cfg.SSDP, flags),
+	)
+}
+
+func ParseDiscoveryFlags(opts *roku.SSDPOptions, flags *pflag.FlagSet) error {
+	return errors.Join(
+		GetFlagT(&opts.Interfaces, flags, "interface", (*pflag.FlagSet).GetStringSlice),
+		GetFlagT(&opts.ExcludeInterfaces, flags, "exclude-interface", (*pflag.FlagSet).GetStringSlice),
+		GetFlagT(&opts.Timeout, flags, "discovery-timeout", (*pflag.FlagSet).GetDuration),
+		GetFlagT(&opts.Retransmits, flags, "retransmits", (*pflag.FlagSet).GetInt),
+		GetFlagT(&opts.MX, flags, "mx", (*pflag.FlagSet).GetInt),
+		GetFlagT(&opts.Expect, flags, "expect", (*pflag.FlagSet).GetInt),
+		parseIPMode(&opts.IP, flags),
 	)
 }
 
+func parseIPMode(target *roku.IPMode, flags *pflag.FlagSet) error {
+	var s string
+	if err := GetFlagT(&s, flags, "ip", (*pflag.FlagSet).GetString); err != nil {
+		return err
+	}
+	var err error
+	*target, err = roku.ParseIPMode(s)
+	return err
+}
+
 func GetFlagT[T any](target *T, flags *pflag.FlagSet, field string, extractor func(*pflag.FlagSet, string) (T, error)) error {
 	var err error
 	*target, err = extractor(flags, field)
 	return err
 }
 
-func GetDevice(ctx context.Context, device string, first bool) (*roku.Device, error) {
-	aliases := map[string]string{}
-	if len(device) > 0 {
-		al, err := aliasing.Load(ctx)
+func GetDevice(ctx context.Context, cfg Cfg) (*roku.Device, error) {
+	if cfg.Group != "" || len(cfg.Tags) > 0 || cfg.All || len(cfg.Devices) > 1 {
+		devs, err := GetDevices(ctx, cfg)
 		if err != nil {
 			return nil, err
 		}
+		if len(devs) != 1 {
+			return nil, fmt.Errorf("%d devices selected, but this command works on one", len(devs))
+		}
+		return devs[0], nil
+	}
+	var device string
+	if len(cfg.Devices) == 1 {
+		device = cfg.Devices[0]
+	}
+	first := cfg.FirstDevice
+	al, err := aliasing.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	aliases := map[string]string{}
+	if len(device) > 0 {
+		var named bool
 		for _, al := range al {
+			if al.Address != "" && al.Name == device {
+				return fromAddress(ctx, al.Address)
+			}
 			aliases[al.USN] = al.Name
+			named = named || al.Name == device
+		}
+		// an alias wins over a name that could also be a host name
+		if !named && roku.IsAddress(device) {
+			return fromAddress(ctx, device)
 		}
 	}
 
 	log := logging.FromContext(ctx)
 
-	var target *roku.Device
-
-	if err := roku.SSDP(ctx, func(dev *roku.Device) error {
+	// without a name or --first every device has to be seen to be sure there
+	// is only one, and the cache can't tell us that
+	if len(device) == 0 && !first {
+		cfg.NoCache = true
+	}
+	disc, err := NewDiscoverer(cfg, al, func(dev *roku.Device) bool {
 		if len(device) > 0 && dev.USN != device && aliases[dev.USN] != device {
 			log.Debug("skipping", zap.String("USN", dev.USN))
-			return nil
+			return false
 		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var target *roku.Device
+
+	if err := disc.Discover(ctx, func(dev *roku.Device) error {
 		if target != nil {
 			return errors.New("more than one roku device found. device name is required")
 		}
@@ -195,4 +365,64 @@ func GetDevice(ctx context.Context, device string, first bool) (*roku.Device, er
 	return target, nil
 }
 
+const (
+	// DefaultDiscovery checks the cache, then searches with SSDP while
+	// contacting static aliases, and scans the subnet if nothing answered.
+	DefaultDiscovery = "cache,ssdp+static,scan"
+
+	// addressTimeout bounds the device-info request used to confirm a device
+	// given by address.
+	addressTimeout = 5 * time.Second
+	// cacheProbeTimeout bounds the device-info check of a cached location. A
+	// device on the LAN answers in a few milliseconds.
+	cacheProbeTimeout = 500 * time.Millisecond
+	// listenWindow is how long the listen backend waits for announcements.
+	listenWindow = 5 * time.Second
+)
+
+// NewDiscoverer builds the discovery chain selected by cfg. Only devices for
+// which keep returns true are reported, and a stage of the chain that finds
+// none of them doesn't end it; nil keeps everything. What is reported is
+// recorded in the cache unless caching is disabled.
+func NewDiscoverer(cfg Cfg, aliases []aliasing.Alias, keep func(*roku.Device) bool) (discovery.Discoverer, error) {
+	var static []string
+	for _, a := range aliases {
+		if a.Address != "" {
+			static = append(static, a.Address)
+		}
+	}
+	backends := map[string]discovery.Discoverer{
+		"ssdp":   discovery.SSDP(cfg.SSDP),
+		"scan":   discovery.Scan(roku.ScanOptions{SSDPOptions: cfg.SSDP, CIDRs: cfg.CIDRs}),
+		"static": discovery.Static(static, addressTimeout),
+		"cache":  discovery.Cached(cacheProbeTimeout),
+		"listen": discovery.Listen(cfg.SSDP, listenWindow),
+	}
+
+	spec := cfg.Discovery
+	if spec == "" {
+		spec = DefaultDiscovery
+	}
+	if cfg.NoCache {
+		spec = discovery.Without(spec, "cache")
+	}
+	disc, err := discovery.Parse(spec, backends)
+	if err != nil {
+		return nil, err
+	}
+	if keep != nil {
+		disc = discovery.Filter(disc, keep)
+	}
+	if cfg.NoCache {
+		return disc, nil
+	}
+	return discovery.Record(disc), nil
+}
+
+func fromAddress(ctx context.Context, addr string) (*roku.Device, error) {
+	pctx, cancel := context.WithTimeout(ctx, addressTimeout)
+	defer cancel()
+	return roku.FromAddress(pctx, addr)
+}
+
 var ErrDeviceFound = errors.New("found device")
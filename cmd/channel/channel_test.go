@@ -0,0 +1,133 @@
+package channel
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dangermike/roku_toy/cache"
+	"github.com/dangermike/roku_toy/config"
+	"github.com/dangermike/roku_toy/emulator"
+	"github.com/dangermike/roku_toy/logging"
+	"github.com/dangermike/roku_toy/roku"
+	"github.com/dangermike/roku_toy/rokutest"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// setup keeps the config file, cache and state of a test in a temporary
+// directory, with conf as the config file.
+func setup(t *testing.T, conf string) context.Context {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "config"))
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(dir, "cache"))
+	t.Setenv("XDG_STATE_HOME", filepath.Join(dir, "state"))
+	path := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(conf), 0o644))
+	config.SetPath(path)
+	t.Cleanup(func() { config.SetPath("") })
+	return logging.NewContext(context.Background(), zap.NewNop())
+}
+
+// servers starts a fake device for each USN.
+func servers(t *testing.T, usns ...string) []*rokutest.Server {
+	t.Helper()
+	srvs := make([]*rokutest.Server, len(usns))
+	for i, usn := range usns {
+		srvs[i] = rokutest.NewServer(t, emulator.DeviceConfig{USN: usn})
+	}
+	return srvs
+}
+
+// cached puts devices in the discovery cache, so the "cache" discovery chain
+// finds them without SSDP.
+func cached(t *testing.T, ctx context.Context, srvs ...*rokutest.Server) {
+	t.Helper()
+	var entries []cache.Entry
+	for _, srv := range srvs {
+		entries = cache.Update(entries, srv.Device, time.Now())
+	}
+	require.NoError(t, cache.Save(ctx, entries))
+}
+
+func TestGetDeviceAliasBeforeAddress(t *testing.T) {
+	srvs := servers(t, "AAA", "BBB")
+	ctx := setup(t, fmt.Sprintf(`
+aliases:
+  - name: den.tv
+    address: %s
+  - name: Mr. Bedroom
+    usn: BBB
+`, srvs[0].URL))
+	cached(t, ctx, srvs[1])
+
+	// both names could be host names, but they are aliases
+	for name, usn := range map[string]string{"den.tv": "AAA", "Mr. Bedroom": "BBB"} {
+		dev, err := GetDevice(ctx, Cfg{Devices: []string{name}, Discovery: "cache"})
+		require.NoError(t, err, name)
+		require.Equal(t, usn, dev.USN, name)
+	}
+
+	devs, err := GetDevices(ctx, Cfg{Devices: []string{"den.tv", "Mr. Bedroom"}, Discovery: "cache"})
+	require.NoError(t, err)
+	require.Len(t, devs, 2)
+	require.Equal(t, "AAA", devs[0].USN)
+	require.Equal(t, "BBB", devs[1].USN)
+}
+
+func TestSet(t *testing.T) {
+	apps := append([]roku.App{{ID: "dev", Version: "1.0.0", Name: "Sideloaded"}}, rokutest.Apps...)
+	srv := rokutest.NewServer(t, emulator.DeviceConfig{USN: "AAA", Apps: apps})
+	ctx := setup(t, fmt.Sprintf(`
+shortcuts:
+  - name: news
+    app: "12"
+    params: {contentId: X}
+  - name: kids
+    app_name: PBS
+  - name: netflix
+    app: "13"
+aliases:
+  - name: den
+    address: %s
+    shortcuts:
+      - name: news
+        app: "837"
+        params: {v: "Y"}
+`, srv.URL))
+
+	for _, test := range []struct {
+		channel string
+		id      string
+		params  url.Values
+	}{
+		{"news", "837", url.Values{"v": {"Y"}}},   // the device's shortcut
+		{"kids", "23353", nil},                    // a global shortcut, by app name
+		{"Netflix", "13", nil},                    // a shortcut wins over an app name
+		{"plex - free movies & tv", "13535", nil}, // the exact app name
+		{"spotify", "22297", nil},                 // a fuzzy match
+		{"dev", "dev", nil},                       // an ID matching no name
+		{"2285", "2285", nil},                     // a numeric ID
+	} {
+		t.Run(test.channel, func(t *testing.T) {
+			srv.Reset()
+			cmd := cmdSet()
+			cmd.SetContext(NewContext(ctx, Cfg{Devices: []string{"den"}}))
+			require.NoError(t, cmd.RunE(cmd, []string{test.channel}))
+			srv.AssertLaunched(t, test.id, test.params)
+			var launches []string
+			for _, r := range srv.Requests() {
+				if strings.HasPrefix(r.Path, "/launch/") {
+					launches = append(launches, r.Path)
+				}
+			}
+			require.Equal(t, []string{"/launch/" + test.id}, launches)
+		})
+	}
+}
@@ -0,0 +1,249 @@
+package channel
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dangermike/roku_toy/aliasing"
+	"github.com/dangermike/roku_toy/cache"
+	"github.com/dangermike/roku_toy/config"
+	"github.com/dangermike/roku_toy/logging"
+	"github.com/dangermike/roku_toy/roku"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+// completionTimeout bounds the request for the apps of a device whose apps
+// aren't cached yet. The shell waits for it on every TAB.
+const completionTimeout = time.Second
+
+// RegisterCompletions completes the device selection flags that AddFlags
+// added to cmd's persistent flags.
+func RegisterCompletions(cmd *cobra.Command) {
+	_ = cmd.RegisterFlagCompletionFunc("device", completeDevices)
+	_ = cmd.RegisterFlagCompletionFunc("group", completeGroups)
+	_ = cmd.RegisterFlagCompletionFunc("tag", completeTags)
+}
+
+// completionContext is the context for completion functions. The root
+// command's PersistentPreRunE only sees the raw command line while
+// completing, so --config is applied here.
+func completionContext(cmd *cobra.Command) context.Context {
+	if path, err := cmd.Flags().GetString("config"); err == nil && path != "" {
+		config.SetPath(path)
+	}
+	return logging.NewContext(cmd.Context(), zap.NewNop())
+}
+
+// completions collects the values that start with a prefix, ignoring case,
+// each once, with an optional description.
+type completions struct {
+	prefix string
+	values []string
+	seen   map[string]bool
+}
+
+func newCompletions(prefix string) *completions {
+	return &completions{prefix: strings.ToLower(prefix), seen: map[string]bool{}}
+}
+
+func (c *completions) add(value, desc string) {
+	if value == "" || c.seen[value] || !strings.HasPrefix(strings.ToLower(value), c.prefix) {
+		return
+	}
+	c.seen[value] = true
+	if desc != "" {
+		value += "\t" + desc
+	}
+	c.values = append(c.values, value)
+}
+
+// completeDevices offers device aliases and the USNs of aliased and cached
+// devices.
+func completeDevices(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	ctx := completionContext(cmd)
+	c := newCompletions(toComplete)
+	al, err := aliasing.Load(ctx)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	for _, a := range al {
+		if a.Group == "" {
+			c.add(a.Name, a.Target())
+		}
+	}
+	for _, a := range al {
+		c.add(a.USN, a.Name)
+	}
+	entries, _ := cache.Load(ctx)
+	for _, e := range entries {
+		c.add(e.USN, e.Location)
+	}
+	return c.values, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeGroups offers group aliases and the groups of cached devices.
+func completeGroups(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	ctx := completionContext(cmd)
+	c := newCompletions(toComplete)
+	al, err := aliasing.Load(ctx)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	for _, a := range al {
+		if a.Group != "" {
+			c.add(a.Name, a.Group)
+		}
+	}
+	entries, _ := cache.Load(ctx)
+	for _, e := range entries {
+		c.add(e.DeviceGroup, aliasing.GroupName(al, e.DeviceGroup))
+	}
+	return c.values, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeTags offers the tags used on aliases.
+func completeTags(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	c := newCompletions(toComplete)
+	al, err := aliasing.Load(completionContext(cmd))
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	for _, a := range al {
+		for _, tag := range a.Tags {
+			c.add(tag, "")
+		}
+	}
+	return c.values, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeChannels offers the shortcuts and the names of the apps installed
+// on the selected devices, or on every device whose apps are cached if none
+// is selected. A selected device whose apps aren't cached is asked for them
+// if its address is known.
+func completeChannels(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	ctx := completionContext(cmd)
+	conf, err := config.Load(ctx)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	al := aliasing.FromConfig(conf)
+	entries, _ := cache.Load(ctx)
+	apps, _ := cache.LoadApps(ctx)
+	usns := selectedUSNs(cmd, al, entries)
+
+	c := newCompletions(toComplete)
+	var alias string
+	if len(usns) == 1 {
+		e, _ := cache.Find(entries, usns[0])
+		a, _ := aliasing.ForDevice(al, usns[0], e.Location)
+		alias = a.Name
+	}
+	for _, s := range conf.ShortcutsFor(alias) {
+		c.add(s.Name, "shortcut")
+	}
+	c.add("home", "")
+
+	if usns == nil {
+		for _, a := range apps {
+			usns = append(usns, a.USN)
+		}
+	}
+	for _, usn := range usns {
+		a, ok := cache.FindApps(apps, usn)
+		if !ok {
+			a = fetchApps(ctx, entries, usn)
+		}
+		for _, app := range a.Apps {
+			c.add(app.Name, app.ID)
+		}
+	}
+	return c.values, cobra.ShellCompDirectiveNoFileComp
+}
+
+// selectedUSNs returns the USNs of the devices chosen by the selection flags
+// of cmd that can be told without discovery, or nil if nothing is selected.
+func selectedUSNs(cmd *cobra.Command, al []aliasing.Alias, entries []cache.Entry) []string {
+	devices, _ := cmd.Flags().GetStringArray("device")
+	group, _ := cmd.Flags().GetString("group")
+	tags, _ := cmd.Flags().GetStringArray("tag")
+	if len(devices) == 0 && group == "" && len(tags) == 0 {
+		return nil
+	}
+	if len(tags) > 0 {
+		for _, a := range aliasing.Tagged(al, tags...) {
+			devices = append(devices, a.Name)
+		}
+	}
+
+	usns := []string{}
+	for _, d := range devices {
+		i := slices.IndexFunc(al, func(a aliasing.Alias) bool { return a.Name == d })
+		switch {
+		case i >= 0 && al[i].USN != "":
+			usns = append(usns, al[i].USN)
+		case i >= 0 || roku.IsAddress(d):
+			addr := d
+			if i >= 0 {
+				addr = al[i].Address
+			}
+			loc, err := roku.ParseAddress(addr)
+			if err != nil {
+				continue
+			}
+			for _, e := range entries {
+				if e.Location == loc.String() {
+					usns = append(usns, e.USN)
+				}
+			}
+		default:
+			usns = append(usns, d)
+		}
+	}
+	if group != "" {
+		id := aliasing.GroupID(al, group)
+		for _, e := range entries {
+			if e.DeviceGroup == id {
+				usns = append(usns, e.USN)
+			}
+		}
+	}
+	slices.Sort(usns)
+	return slices.Compact(usns)
+}
+
+// fetchApps asks the cached device with usn for its apps and caches them.
+func fetchApps(ctx context.Context, entries []cache.Entry, usn string) cache.Apps {
+	e, ok := cache.Find(entries, usn)
+	if !ok {
+		return cache.Apps{}
+	}
+	dev, err := e.Device()
+	if err != nil {
+		return cache.Apps{}
+	}
+	ctx, cancel := context.WithTimeout(ctx, completionTimeout)
+	defer cancel()
+	apps, err := dev.QueryApps(ctx)
+	if err != nil {
+		return cache.Apps{}
+	}
+	a, _ := cache.RecordApps(ctx, usn, apps, time.Now())
+	return a
+}
+
+// recordApps caches the apps installed on device for completing channel
+// names. Failing to do so doesn't fail the command.
+func recordApps(ctx context.Context, device *roku.Device, apps []roku.App) {
+	if device.USN == "" {
+		return
+	}
+	if _, err := cache.RecordApps(ctx, device.USN, apps, time.Now()); err != nil {
+		logging.FromContext(ctx).Debug("failed to cache apps", zap.String("usn", device.USN), zap.Error(err))
+	}
+}
@@ -0,0 +1,360 @@
+package channel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/dangermike/roku_toy/aliasing"
+	"github.com/dangermike/roku_toy/output"
+	"github.com/dangermike/roku_toy/roku"
+)
+
+// target is a device a command runs against, or why it couldn't be found.
+type target struct {
+	name string
+	dev  *roku.Device
+	err  error
+}
+
+// Result is the record printed for each device when a command runs against
+// several. The fields of what the command returned for the device follow it,
+// once for each record if it returned a list.
+type Result struct {
+	Device   string `json:"device"`
+	USN      string `json:"usn"`
+	Location string `json:"location"`
+	OK       bool   `json:"ok"`
+	Error    string `json:"error"`
+}
+
+// ExitError reports that a command failed on some of its devices. The
+// process exits with 1 if every device failed and 2 if only some did.
+type ExitError struct {
+	Failed, Total int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("%d of %d devices failed", e.Failed, e.Total)
+}
+
+func (e *ExitError) ExitCode() int {
+	if e.Failed == e.Total {
+		return 1
+	}
+	return 2
+}
+
+// fanOut reports whether cfg selects devices in a way that can match more
+// than one, which decides whether results are printed per device.
+func (cfg Cfg) fanOut() bool {
+	return cfg.Group != "" || len(cfg.Tags) > 0 || cfg.All || len(cfg.Devices) > 1 || cfg.JSON
+}
+
+// ForEach runs fn against every device selected by cfg at the same time and
+// prints what it returns, a record, a list of records or nil, with
+// cfg.Output. When a single device is selected its records are printed as
+// they are. Otherwise every device gets a Result, merged with each of its
+// records, and failures, including devices that could not be found, are
+// reported in place. If any device failed an *ExitError is returned.
+func ForEach(ctx context.Context, cfg Cfg, fn func(context.Context, *roku.Device) (any, error)) error {
+	targets, err := resolve(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	if cfg.Output == nil {
+		if err := ParseOutput(&cfg, nil); err != nil {
+			return err
+		}
+	}
+	if !cfg.fanOut() && len(targets) == 1 {
+		if targets[0].err != nil {
+			return targets[0].err
+		}
+		v, err := fn(ctx, targets[0].dev)
+		if err != nil {
+			return err
+		}
+		return cfg.Output.Print(v)
+	}
+
+	results := make([]Result, len(targets))
+	values := make([]any, len(targets))
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		results[i] = Result{Device: t.name}
+		if t.err != nil {
+			results[i].Error = t.err.Error()
+			continue
+		}
+		results[i].USN = t.dev.USN
+		results[i].Location = t.dev.Location.String()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := fn(ctx, t.dev)
+			values[i] = v
+			if err != nil {
+				results[i].Error = err.Error()
+			} else {
+				results[i].OK = true
+			}
+		}()
+	}
+	wg.Wait()
+
+	var failed int
+	var rows []output.Object
+	for i, r := range results {
+		if !r.OK {
+			failed++
+		}
+		for _, rec := range records(values[i]) {
+			row, err := output.Merge(r, rec)
+			if err != nil {
+				return err
+			}
+			rows = append(rows, row)
+		}
+	}
+	if err := cfg.Output.Print(rows); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return &ExitError{Failed: failed, Total: len(results)}
+	}
+	return nil
+}
+
+// records splits a list of records into its elements. Anything else, and an
+// empty list, is a single record, nil standing for none.
+func records(v any) []any {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice {
+		return []any{v}
+	}
+	if rv.Len() == 0 {
+		return []any{nil}
+	}
+	recs := make([]any, rv.Len())
+	for i := range recs {
+		recs[i] = rv.Index(i).Interface()
+	}
+	return recs
+}
+
+// GetDevices returns every device selected by cfg: the members of --group,
+// the aliases with every --tag, everything with --all, each --device, or else
+// the single device GetDevice picks. It fails if any named device can't be found.
+func GetDevices(ctx context.Context, cfg Cfg) ([]*roku.Device, error) {
+	targets, err := resolve(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	devs := make([]*roku.Device, 0, len(targets))
+	var errs []error
+	for _, t := range targets {
+		if t.err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", t.name, t.err))
+			continue
+		}
+		devs = append(devs, t.dev)
+	}
+	return devs, errors.Join(errs...)
+}
+
+func resolve(ctx context.Context, cfg Cfg) ([]target, error) {
+	var selectors int
+	for _, set := range []bool{cfg.Group != "", len(cfg.Tags) > 0, cfg.All, len(cfg.Devices) > 0} {
+		if set {
+			selectors++
+		}
+	}
+	if selectors > 1 {
+		return nil, errors.New("only one of --device, --group, --tag and --all can be used")
+	}
+	if cfg.FirstDevice && (cfg.Group != "" || len(cfg.Tags) > 0 || cfg.All || len(cfg.Devices) > 1) {
+		return nil, errors.New("--first can't be combined with --group, --tag, --all or several --device")
+	}
+
+	if cfg.Group == "" && len(cfg.Tags) == 0 && !cfg.All && len(cfg.Devices) <= 1 {
+		dev, err := GetDevice(ctx, cfg)
+		if err != nil {
+			return nil, err
+		}
+		name := dev.USN
+		if len(cfg.Devices) == 1 {
+			name = cfg.Devices[0]
+		}
+		return []target{{name: name, dev: dev}}, nil
+	}
+
+	al, err := aliasing.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(cfg.Tags) > 0 {
+		tagged := aliasing.Tagged(al, cfg.Tags...)
+		if len(tagged) == 0 {
+			return nil, fmt.Errorf("no aliases tagged '%s'", strings.Join(cfg.Tags, "', '"))
+		}
+		cfg.Devices = nil
+		for _, a := range tagged {
+			cfg.Devices = append(cfg.Devices, a.Name)
+		}
+	}
+	if len(cfg.Devices) > 1 || len(cfg.Tags) > 0 {
+		return resolveNamed(ctx, cfg, al)
+	}
+
+	var keep func(*roku.Device) bool
+	if cfg.Group != "" {
+		id := aliasing.GroupID(al, cfg.Group)
+		keep = func(dev *roku.Device) bool { return dev.DeviceGroup == id }
+	}
+	devs, err := discoverAll(ctx, cfg, al, keep)
+	if err != nil {
+		return nil, err
+	}
+	if len(devs) == 0 {
+		if cfg.Group != "" {
+			return nil, fmt.Errorf("no devices found in group '%s'", cfg.Group)
+		}
+		return nil, errors.New("no roku devices found")
+	}
+	targets := make([]target, len(devs))
+	for i, dev := range devs {
+		targets[i] = target{name: deviceName(al, dev), dev: dev}
+	}
+	return targets, nil
+}
+
+// discoverAll returns every device for which keep is true, or every device
+// if keep is nil, sorted by USN.
+func discoverAll(ctx context.Context, cfg Cfg, al []aliasing.Alias, keep func(*roku.Device) bool) ([]*roku.Device, error) {
+	// a cached device would stop the chain before the rest are found
+	cfg.NoCache = true
+	disc, err := NewDiscoverer(cfg, al, keep)
+	if err != nil {
+		return nil, err
+	}
+	var devs []*roku.Device
+	if err := disc.Discover(ctx, func(dev *roku.Device) error {
+		devs = append(devs, dev)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	slices.SortFunc(devs, func(a, b *roku.Device) int { return strings.Compare(a.USN, b.USN) })
+	return devs, nil
+}
+
+// resolveNamed finds each of several --device values. Addresses are
+// contacted directly while everything else is found in one discovery pass
+// that ends as soon as all of them have answered.
+func resolveNamed(ctx context.Context, cfg Cfg, al []aliasing.Alias) ([]target, error) {
+	targets := make([]target, len(cfg.Devices))
+	pending := map[string][]int{}
+	var wg sync.WaitGroup
+	for i, name := range cfg.Devices {
+		targets[i].name = name
+		addr := ""
+		usn := name
+		var named bool
+		for _, a := range al {
+			if a.Name != name {
+				continue
+			}
+			named = true
+			if a.Address != "" {
+				addr = a.Address
+			} else if a.USN != "" {
+				usn = a.USN
+			}
+		}
+		if !named && roku.IsAddress(name) {
+			addr = name
+		}
+		if addr != "" {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				targets[i].dev, targets[i].err = fromAddress(ctx, addr)
+			}()
+			continue
+		}
+		pending[usn] = append(pending[usn], i)
+	}
+
+	if len(pending) > 0 {
+		// the cache stage would end the chain after finding only some of them
+		if len(pending) > 1 {
+			cfg.NoCache = true
+		}
+		disc, err := NewDiscoverer(cfg, al, func(dev *roku.Device) bool {
+			_, ok := pending[dev.USN]
+			return ok
+		})
+		if err != nil {
+			wg.Wait()
+			return nil, err
+		}
+		remaining := len(pending)
+		err = disc.Discover(ctx, func(dev *roku.Device) error {
+			idx := pending[dev.USN]
+			if targets[idx[0]].dev != nil {
+				return nil
+			}
+			for _, i := range idx {
+				targets[i].dev = dev
+			}
+			if remaining--; remaining == 0 {
+				return ErrDeviceFound
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, ErrDeviceFound) {
+			wg.Wait()
+			return nil, err
+		}
+		for _, idx := range pending {
+			for _, i := range idx {
+				if targets[i].dev == nil {
+					targets[i].err = errors.New("no matching roku found")
+				}
+			}
+		}
+	}
+	wg.Wait()
+
+	// the same device may have been named twice, e.g. by alias and USN
+	seen := map[string]struct{}{}
+	return slices.DeleteFunc(targets, func(t target) bool {
+		if t.dev == nil {
+			return false
+		}
+		if _, ok := seen[t.dev.USN]; ok {
+			return true
+		}
+		seen[t.dev.USN] = struct{}{}
+		return false
+	}), nil
+}
+
+// deviceName is the alias of dev, or its USN if it has none.
+func deviceName(al []aliasing.Alias, dev *roku.Device) string {
+	if name := aliasOf(al, dev); name != "" {
+		return name
+	}
+	return dev.USN
+}
+
+// aliasOf is the alias of dev, or "" if it has none.
+func aliasOf(al []aliasing.Alias, dev *roku.Device) string {
+	a, _ := aliasing.ForDevice(al, dev.USN, dev.Location.String())
+	return a.Name
+}
@@ -0,0 +1,144 @@
+package channel
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/dangermike/roku_toy/output"
+	"github.com/dangermike/roku_toy/roku"
+	"github.com/stretchr/testify/require"
+)
+
+// addressAliases names each server by alias, pointing at its address so it
+// is found without discovery, and tags them.
+func addressAliases(names []string, urls []string, tags []string) string {
+	var sb strings.Builder
+	sb.WriteString("aliases:\n")
+	for i, name := range names {
+		fmt.Fprintf(&sb, "  - name: %s\n    address: %s\n    tags: [%s]\n", name, urls[i], tags[i])
+	}
+	return sb.String()
+}
+
+// jsonl parses the records ForEach printed.
+func jsonl(t *testing.T, out *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var recs []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var rec map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &rec), line)
+		recs = append(recs, rec)
+	}
+	return recs
+}
+
+func TestForEach(t *testing.T) {
+	srvs := servers(t, "AAA", "BBB", "CCC")
+	ctx := setup(t, addressAliases(
+		[]string{"den", "office", "kitchen"},
+		[]string{srvs[0].URL, srvs[1].URL, srvs[2].URL},
+		[]string{"night", "night, upstairs", "upstairs"},
+	))
+	press := func(ctx context.Context, dev *roku.Device) (any, error) {
+		return map[string]string{"key": "Home"}, dev.Keypress(ctx, "Home")
+	}
+	run := func(cfg Cfg) ([]map[string]any, error) {
+		var out bytes.Buffer
+		var err error
+		cfg.Output, err = output.New(&out, output.JSONL)
+		require.NoError(t, err)
+		err = ForEach(ctx, cfg, press)
+		return jsonl(t, &out), err
+	}
+
+	srvs[1].Fail(http.MethodPost, "/keypress/Home", http.StatusServiceUnavailable, 1)
+	recs, err := run(Cfg{Devices: []string{"den", "office", "kitchen"}})
+	var exit *ExitError
+	require.ErrorAs(t, err, &exit)
+	require.Equal(t, ExitError{Failed: 1, Total: 3}, *exit)
+	require.Equal(t, 2, exit.ExitCode(), "some devices failed")
+	require.Len(t, recs, 3)
+	for i, name := range []string{"den", "office", "kitchen"} {
+		require.Equal(t, name, recs[i]["device"])
+		require.Equal(t, "Home", recs[i]["key"], "the command's record is merged in")
+	}
+	require.Equal(t, true, recs[0]["ok"])
+	require.Equal(t, false, recs[1]["ok"])
+	require.Contains(t, recs[1]["error"], "503")
+	require.Equal(t, "CCC", recs[2]["usn"])
+	srvs[0].AssertKeys(t, "Home")
+	srvs[2].AssertKeys(t, "Home")
+
+	srvs[1].Fail(http.MethodPost, "/keypress/Home", http.StatusServiceUnavailable, 1)
+	srvs[2].Fail(http.MethodPost, "/keypress/Home", http.StatusServiceUnavailable, 1)
+	recs, err = run(Cfg{Tags: []string{"upstairs"}})
+	require.ErrorAs(t, err, &exit)
+	require.Equal(t, ExitError{Failed: 2, Total: 2}, *exit)
+	require.Equal(t, 1, exit.ExitCode(), "every device failed")
+	require.Len(t, recs, 2)
+
+	// a device that can't be found is reported in place
+	recs, err = run(Cfg{Devices: []string{"den", "http://127.0.0.1:1/"}})
+	require.ErrorAs(t, err, &exit)
+	require.Equal(t, ExitError{Failed: 1, Total: 2}, *exit)
+	require.Equal(t, "http://127.0.0.1:1/", recs[1]["device"])
+	require.Contains(t, recs[1]["error"], "no roku at")
+
+	recs, err = run(Cfg{Tags: []string{"night", "upstairs"}})
+	require.NoError(t, err)
+	require.Len(t, recs, 1)
+	require.Equal(t, "office", recs[0]["device"])
+
+	// one device prints the command's record as it is
+	recs, err = run(Cfg{Devices: []string{"kitchen"}})
+	require.NoError(t, err)
+	require.Equal(t, []map[string]any{{"key": "Home"}}, recs)
+}
+
+func TestGetDevicesDeduplicates(t *testing.T) {
+	srvs := servers(t, "AAA", "BBB")
+	ctx := setup(t, addressAliases([]string{"den", "office"}, []string{srvs[0].URL, srvs[1].URL}, []string{"", ""}))
+
+	devs, err := GetDevices(ctx, Cfg{Devices: []string{"den", srvs[0].URL, "office", "den"}})
+	require.NoError(t, err)
+	require.Len(t, devs, 2)
+	require.Equal(t, "AAA", devs[0].USN)
+	require.Equal(t, "BBB", devs[1].USN)
+
+	_, err = GetDevice(ctx, Cfg{Devices: []string{"den", "office"}})
+	require.ErrorContains(t, err, "2 devices selected, but this command works on one")
+	dev, err := GetDevice(ctx, Cfg{Devices: []string{"den", srvs[0].URL}})
+	require.NoError(t, err, "both name the same device")
+	require.Equal(t, "AAA", dev.USN)
+}
+
+func TestSelectorCombinations(t *testing.T) {
+	ctx := setup(t, "")
+	for _, test := range []struct {
+		name string
+		cfg  Cfg
+		err  string
+	}{
+		{"device and group", Cfg{Devices: []string{"den"}, Group: "lobby"}, "only one of --device, --group, --tag and --all"},
+		{"tag and all", Cfg{Tags: []string{"night"}, All: true}, "only one of"},
+		{"group and all", Cfg{Group: "lobby", All: true}, "only one of"},
+		{"first and devices", Cfg{FirstDevice: true, Devices: []string{"den", "office"}}, "--first can't be combined"},
+		{"first and all", Cfg{FirstDevice: true, All: true}, "--first can't be combined"},
+		{"unknown tag", Cfg{Tags: []string{"night"}}, "no aliases tagged 'night'"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			err := ForEach(ctx, test.cfg, func(context.Context, *roku.Device) (any, error) {
+				t.Fatal("no device should be selected")
+				return nil, nil
+			})
+			require.ErrorContains(t, err, test.err)
+			_, err = GetDevices(ctx, test.cfg)
+			require.ErrorContains(t, err, test.err)
+		})
+	}
+}
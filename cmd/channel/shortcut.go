@@ -0,0 +1,114 @@
+package channel
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/url"
+	"strings"
+
+	"github.com/dangermike/roku_toy/aliasing"
+	"github.com/dangermike/roku_toy/config"
+	"github.com/dangermike/roku_toy/logging"
+	"github.com/dangermike/roku_toy/output"
+	"github.com/dangermike/roku_toy/roku"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+func cmdFavorites() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "favorites",
+		Short: "List channel shortcuts and whether their apps are installed",
+		RunE:  favoritesE,
+	}
+	AddDiscoveryFlags(cmd.Flags())
+	AddFanOutFlags(cmd.Flags())
+	return cmd
+}
+
+func favoritesE(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	cfg := FromContext(ctx)
+	conf, err := config.Load(ctx)
+	if err != nil {
+		return err
+	}
+	al := aliasing.FromConfig(conf)
+	return ForEach(ctx, cfg, func(ctx context.Context, device *roku.Device) (any, error) {
+		shortcuts := conf.ShortcutsFor(aliasOf(al, device))
+		apps, err := device.QueryApps(ctx)
+		if err != nil {
+			return nil, err
+		}
+		recordApps(ctx, device, apps)
+		favorites := make([]output.Favorite, len(shortcuts))
+		for i, s := range shortcuts {
+			favorites[i] = favorite(s, apps)
+		}
+		return favorites, nil
+	})
+}
+
+// favorite shows where s leads and whether that app is installed.
+func favorite(s config.Shortcut, apps []roku.App) output.Favorite {
+	f := output.Favorite{Name: s.Name, AppID: s.App, AppName: s.AppName, Params: s.Params}
+	if app := shortcutApp(s, apps); app != nil {
+		f.AppID, f.AppName, f.Installed = app.ID, html.UnescapeString(app.Name), true
+	} else if s.App == "0" {
+		f.AppName, f.Installed = "home", true
+	}
+	return f
+}
+
+// shortcutApp finds the app s launches among apps, or nil if it isn't
+// installed. Names have to match exactly, apart from case.
+func shortcutApp(s config.Shortcut, apps []roku.App) *roku.App {
+	for i, a := range apps {
+		if s.App != "" && a.ID == s.App {
+			return &apps[i]
+		}
+	}
+	if s.AppName != "" {
+		return appNamed(apps, s.AppName)
+	}
+	return nil
+}
+
+// appNamed finds the app called name among apps, apart from case.
+func appNamed(apps []roku.App, name string) *roku.App {
+	for i, a := range apps {
+		if strings.EqualFold(html.UnescapeString(a.Name), name) {
+			return &apps[i]
+		}
+	}
+	return nil
+}
+
+func shortcutParams(s config.Shortcut) url.Values {
+	params := url.Values{}
+	for k, v := range s.Params {
+		params.Set(k, v)
+	}
+	return params
+}
+
+// launchShortcut launches the app s points at. Shortcuts by app name look the
+// app up on the device; shortcuts by ID are launched without checking.
+func launchShortcut(ctx context.Context, device *roku.Device, s config.Shortcut) error {
+	logging.FromContext(ctx).Debug("using shortcut", zap.String("shortcut", s.Name))
+	id := s.App
+	if id == "" {
+		apps, err := device.QueryApps(ctx)
+		if err != nil {
+			return err
+		}
+		recordApps(ctx, device, apps)
+		app := shortcutApp(s, apps)
+		if app == nil {
+			return fmt.Errorf("shortcut '%s': app '%s' is not installed", s.Name, s.AppName)
+		}
+		id = app.ID
+	}
+	return device.LaunchWith(ctx, id, shortcutParams(s))
+}
@@ -0,0 +1,48 @@
+package completion
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+func Cmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "completion [bash|zsh|fish]",
+		Short: "print a shell completion script",
+		Long: `Prints the script that completes roku_toy commands, device aliases, USNs
+and channel names in the given shell. Channel names come from the apps seen
+on each device, which are cached whenever roku_toy lists them.
+
+  bash: source <(roku_toy completion bash)
+  zsh:  roku_toy completion zsh > "${fpath[1]}/_roku_toy"
+  fish: roku_toy completion fish > ~/.config/fish/completions/roku_toy.fish`,
+		Args:                  cobra.ExactArgs(1),
+		ValidArgs:             []string{"bash", "zsh", "fish"},
+		DisableFlagsInUseLine: true,
+		RunE:                  completionE,
+	}
+	cmd.Flags().Bool("no-descriptions", false, "leave out the descriptions shown next to completions")
+	return cmd
+}
+
+func completionE(cmd *cobra.Command, args []string) error {
+	noDesc, err := cmd.Flags().GetBool("no-descriptions")
+	if err != nil {
+		return err
+	}
+	root := cmd.Root()
+	switch args[0] {
+	case "bash":
+		return root.GenBashCompletionV2(os.Stdout, !noDesc)
+	case "zsh":
+		if noDesc {
+			return root.GenZshCompletionNoDesc(os.Stdout)
+		}
+		return root.GenZshCompletion(os.Stdout)
+	case "fish":
+		return root.GenFishCompletion(os.Stdout, !noDesc)
+	}
+	return fmt.Errorf("unsupported shell '%s' (want bash, zsh or fish)", args[0])
+}
@@ -0,0 +1,189 @@
+package dev
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/dangermike/roku_toy/cmd/channel"
+	"github.com/dangermike/roku_toy/logging"
+	"github.com/dangermike/roku_toy/output"
+	"github.com/dangermike/roku_toy/snapshot"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+)
+
+func Cmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "dev",
+		Short: "developer mode tools",
+	}
+
+	snap := &cobra.Command{
+		Use:   "snapshot",
+		Short: "capture and compare screenshots of the dev channel",
+	}
+	snap.AddCommand(cmdCapture(), cmdCompare())
+	cmd.AddCommand(snap)
+
+	return cmd
+}
+
+func addPasswordFlag(flags *pflag.FlagSet) {
+	flags.StringP("password", "p", "", "developer web server password (default $ROKU_DEV_PASSWORD)")
+}
+
+func getPassword(flags *pflag.FlagSet) (string, error) {
+	pw, err := flags.GetString("password")
+	if err != nil {
+		return "", err
+	}
+	if pw == "" {
+		pw = os.Getenv("ROKU_DEV_PASSWORD")
+	}
+	if pw == "" {
+		return "", errors.New("developer password required")
+	}
+	return pw, nil
+}
+
+func cmdCapture() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "capture <file.png>",
+		Short: "Save a screenshot of the running dev channel",
+		RunE:  captureE,
+	}
+	channel.AddDiscoveryFlags(cmd.Flags())
+	addPasswordFlag(cmd.Flags())
+	return cmd
+}
+
+func cmdCompare() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "compare <name>",
+		Short: "Compare a screenshot to the golden image <golden>/<name>.png",
+		Long: `Capture a screenshot and compare it to the golden image <golden>/<name>.png.
+On a mismatch a diff image is written next to the golden as <name>.diff.png
+with differing pixels in red and masked regions in gray. With --update the
+screenshot replaces the golden instead.`,
+		RunE: compareE,
+	}
+	channel.AddDiscoveryFlags(cmd.Flags())
+	addPasswordFlag(cmd.Flags())
+	def := snapshot.DefaultOptions()
+	cmd.Flags().String("golden", "", "directory holding golden images (required)")
+	cmd.Flags().Float64("threshold", def.Threshold, "per-pixel perceptual color distance (0-1) treated as a difference")
+	cmd.Flags().Float64("max-diff", def.MaxDiffRatio, "fraction of pixels allowed to differ")
+	cmd.Flags().StringArray("mask", nil, "region to ignore as x,y,w,h (repeatable)")
+	cmd.Flags().Bool("update", false, "write the screenshot as the new golden image")
+	_ = cmd.MarkFlagRequired("golden")
+	return cmd
+}
+
+func captureE(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.New("output file required")
+	}
+	pw, err := getPassword(cmd.Flags())
+	if err != nil {
+		return err
+	}
+	ctx := cmd.Context()
+	device, err := channel.GetDevice(ctx, channel.FromContext(ctx))
+	if err != nil {
+		return err
+	}
+
+	img, err := device.Screenshot(ctx, pw)
+	if err != nil {
+		return err
+	}
+	return snapshot.Save(args[0], img)
+}
+
+func compareE(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.New("snapshot name required")
+	}
+	pw, err := getPassword(cmd.Flags())
+	if err != nil {
+		return err
+	}
+	opts, dir, update, err := parseCompareFlags(cmd.Flags())
+	if err != nil {
+		return err
+	}
+	ctx := cmd.Context()
+	log := logging.FromContext(ctx)
+	cfg := channel.FromContext(ctx)
+	device, err := channel.GetDevice(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	goldenPath := filepath.Join(dir, args[0]+".png")
+	diffPath := filepath.Join(dir, args[0]+".diff.png")
+
+	img, err := device.Screenshot(ctx, pw)
+	if err != nil {
+		return err
+	}
+
+	if update {
+		if err := snapshot.Save(goldenPath, img); err != nil {
+			return err
+		}
+		return cfg.Output.Print(output.Snapshot{Name: args[0], OK: true, Updated: true})
+	}
+
+	golden, err := snapshot.Load(goldenPath)
+	if err != nil {
+		return fmt.Errorf("failed to load golden image (use --update to create it): %w", err)
+	}
+
+	res, cmpErr := snapshot.Compare(img, golden, opts)
+	rec := output.Snapshot{Name: args[0], OK: cmpErr == nil, DiffPixels: res.DiffPixels, TotalPixels: res.TotalPixels}
+	if cmpErr == nil {
+		// a stale diff from an earlier failure would be misleading
+		if err := os.Remove(diffPath); err != nil && !os.IsNotExist(err) {
+			log.Debug("failed to remove old diff", zap.String("path", diffPath), zap.Error(err))
+		}
+		return cfg.Output.Print(rec)
+	}
+	if res.Diff != nil {
+		if err := snapshot.Save(diffPath, res.Diff); err != nil {
+			return errors.Join(cmpErr, err)
+		}
+		rec.DiffPath = diffPath
+		cmpErr = fmt.Errorf("%w (diff written to %s)", cmpErr, diffPath)
+	}
+	return errors.Join(cfg.Output.Print(rec), cmpErr)
+}
+
+func parseCompareFlags(flags *pflag.FlagSet) (snapshot.Options, string, bool, error) {
+	var (
+		opts   snapshot.Options
+		dir    string
+		update bool
+		masks  []string
+	)
+	if err := errors.Join(
+		channel.GetFlagT(&dir, flags, "golden", (*pflag.FlagSet).GetString),
+		channel.GetFlagT(&update, flags, "update", (*pflag.FlagSet).GetBool),
+		channel.GetFlagT(&opts.Threshold, flags, "threshold", (*pflag.FlagSet).GetFloat64),
+		channel.GetFlagT(&opts.MaxDiffRatio, flags, "max-diff", (*pflag.FlagSet).GetFloat64),
+		channel.GetFlagT(&masks, flags, "mask", (*pflag.FlagSet).GetStringArray),
+	); err != nil {
+		return opts, "", false, err
+	}
+	for _, m := range masks {
+		r, err := snapshot.ParseMask(m)
+		if err != nil {
+			return opts, "", false, err
+		}
+		opts.Masks = append(opts.Masks, r)
+	}
+	return opts, dir, update, nil
+}
@@ -1,22 +1,41 @@
 package alias
 
 import (
+	"bufio"
+	"context"
 	"errors"
+	"fmt"
+	"io"
 	"slices"
+	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/dangermike/roku_toy/aliasing"
+	"github.com/dangermike/roku_toy/cmd/channel"
 	"github.com/dangermike/roku_toy/logging"
+	"github.com/dangermike/roku_toy/roku"
 	"github.com/spf13/cobra"
+	"go.uber.org/zap"
 )
 
 func Alias() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "alias",
+		Use:   "alias [<USN|address|device name>] <name>",
 		Short: "add an alias for a Roku device",
-		RunE:  aliasE,
+		Long: `The device can be given by USN, by address or by the name it shows in
+its settings (device-info's user-device-name or friendly-device-name). It is
+checked against the devices on the network unless --force is given. Without a
+device, the devices found are listed to pick from. With --group <ID> the name
+is given to that SSDP device group instead.`,
+		Args: cobra.RangeArgs(1, 2),
+		RunE: aliasE,
 	}
 
-	cmd.Flags().BoolP("verbose", "v", false, "verbose logging")
+	cmd.Flags().Bool("force", false, "save the alias without checking that the device or group exists")
+	cmd.Flags().String("room", "", "the room the device is in")
+	cmd.Flags().String("notes", "", "free-form notes about the device")
+	channel.AddDiscoveryFlags(cmd.Flags())
 
 	return cmd
 }
@@ -28,51 +47,213 @@ func Unalias() *cobra.Command {
 		RunE:  unaliasE,
 	}
 
-	cmd.Flags().BoolP("verbose", "v", false, "verbose logging")
-
 	return cmd
 }
 
 func aliasE(cmd *cobra.Command, args []string) error {
-	debug, err := cmd.Flags().GetBool("verbose")
+	ctx := cmd.Context()
+	cfg := channel.FromContext(ctx)
+	flags := cmd.Flags()
+	force, err := flags.GetBool("force")
 	if err != nil {
 		return err
 	}
+	group := cfg.Group
+	switch {
+	case group != "" && len(args) != 1:
+		return errors.New("with --group <ID> only the name is given")
+	case group == "" && len(args) == 1 && force:
+		return errors.New("--force needs both a target and a name")
+	}
+	// the selection flags say what to alias here, not which devices to search
+	cfg.Devices, cfg.Group, cfg.Tags, cfg.FirstDevice = nil, "", nil, false
+
+	var alias aliasing.Alias
+	switch {
+	case group != "":
+		alias, err = groupAlias(ctx, cfg, group, args[0], force)
+	case len(args) == 1:
+		alias, err = pick(ctx, cmd, cfg, args[0])
+	case roku.IsAddress(args[0]):
+		alias, err = addressAlias(ctx, args[0], args[1], force)
+	case force:
+		alias = aliasing.Alias{USN: args[0], Name: args[1]}
+	default:
+		alias, err = deviceAlias(ctx, cfg, args[0], args[1])
+	}
+	if err != nil {
+		return err
+	}
+
+	return aliasing.Update(ctx, func(aliases []aliasing.Alias) ([]aliasing.Alias, error) {
+		for _, old := range aliasing.Replaced(aliases, alias) {
+			fmt.Fprintf(cmd.ErrOrStderr(), "warning: replacing alias '%s' for %s\n", old.Name, old.Target())
+		}
+		// renaming a device or pointing a name somewhere else keeps what is
+		// known about it, with the name taking precedence
+		for _, old := range aliases {
+			if old.Target() == alias.Target() {
+				alias.Room, alias.Tags, alias.Notes = old.Room, old.Tags, old.Notes
+			}
+		}
+		for _, old := range aliases {
+			if old.Name == alias.Name {
+				alias.Room, alias.Tags, alias.Notes = old.Room, old.Tags, old.Notes
+			}
+		}
+		if flags.Changed("room") {
+			alias.Room, _ = flags.GetString("room")
+		}
+		if flags.Changed("notes") {
+			alias.Notes, _ = flags.GetString("notes")
+		}
+		return append(aliases, alias), nil
+	})
+}
+
+// candidate is a device found on the network along with its device-info,
+// which is left empty if the device didn't answer.
+type candidate struct {
+	dev  *roku.Device
+	info roku.DeviceInfo
+}
 
-	if len(args) != 2 {
-		return errors.New("USN and name required")
+// names are the names the device goes by in its settings.
+func (c candidate) names() []string {
+	var names []string
+	for _, n := range []string{c.info.UserDeviceName, c.info.FriendlyDeviceName, c.info.DefaultDeviceName} {
+		if n != "" && !slices.Contains(names, n) {
+			names = append(names, n)
+		}
 	}
+	return names
+}
 
-	ctx := logging.NewContext(cmd.Context(), logging.Configure(debug))
+func (c candidate) String() string {
+	s := fmt.Sprintf("%s %s", c.dev.USN, c.dev.Location)
+	if names := c.names(); len(names) > 0 {
+		s += fmt.Sprintf(" (%s)", names[0])
+	}
+	return s
+}
 
-	aliases, err := aliasing.Load(ctx)
+// discover finds every device on the network and asks each for its
+// device-info.
+func discover(ctx context.Context, cfg channel.Cfg) ([]candidate, error) {
+	cfg.All = true
+	devs, err := channel.GetDevices(ctx, cfg)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	log := logging.FromContext(ctx)
+	cands := make([]candidate, len(devs))
+	var wg sync.WaitGroup
+	for i, dev := range devs {
+		cands[i].dev = dev
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			info, err := dev.QueryDeviceInfo(ctx)
+			if err != nil {
+				log.Debug("failed to get device info", zap.String("usn", dev.USN), zap.Error(err))
+				return
+			}
+			cands[i].info = info
+		}()
 	}
-	aliases = append(aliases, aliasing.Alias{USN: args[0], Name: args[1]})
-	return aliasing.Save(ctx, aliases)
+	wg.Wait()
+	return cands, nil
 }
 
-func unaliasE(cmd *cobra.Command, args []string) error {
-	debug, err := cmd.Flags().GetBool("verbose")
+// deviceAlias names the device whose USN or device name is target.
+func deviceAlias(ctx context.Context, cfg channel.Cfg, target, name string) (aliasing.Alias, error) {
+	cands, err := discover(ctx, cfg)
 	if err != nil {
-		return err
+		return aliasing.Alias{}, err
+	}
+	for _, c := range cands {
+		if strings.EqualFold(c.dev.USN, target) {
+			return aliasing.Alias{USN: c.dev.USN, Name: name}, nil
+		}
+	}
+	var matches []candidate
+	for _, c := range cands {
+		if slices.ContainsFunc(c.names(), func(n string) bool { return strings.EqualFold(n, target) }) {
+			matches = append(matches, c)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return aliasing.Alias{}, fmt.Errorf("no roku with USN or name '%s' found (use --force to alias it anyway)", target)
+	case 1:
+		return aliasing.Alias{USN: matches[0].dev.USN, Name: name}, nil
 	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d rokus are named '%s', use the USN instead:", len(matches), target)
+	for _, c := range matches {
+		fmt.Fprintf(&sb, "\n  %s", c)
+	}
+	return aliasing.Alias{}, errors.New(sb.String())
+}
 
-	if len(args) != 1 {
-		return errors.New("USN or name required")
+// addressAlias names a static address after checking that a Roku answers
+// there.
+func addressAlias(ctx context.Context, addr, name string, force bool) (aliasing.Alias, error) {
+	if !force {
+		if _, err := roku.FromAddress(ctx, addr); err != nil {
+			return aliasing.Alias{}, fmt.Errorf("%w (use --force to alias it anyway)", err)
+		}
 	}
+	return aliasing.Alias{Address: addr, Name: name}, nil
+}
 
-	ctx := logging.NewContext(cmd.Context(), logging.Configure(debug))
+// groupAlias names a device group after checking that a device on the
+// network belongs to it.
+func groupAlias(ctx context.Context, cfg channel.Cfg, id, name string, force bool) (aliasing.Alias, error) {
+	alias := aliasing.Alias{Group: id, Name: name}
+	if force {
+		return alias, nil
+	}
+	cands, err := discover(ctx, cfg)
+	if err != nil {
+		return aliasing.Alias{}, err
+	}
+	if !slices.ContainsFunc(cands, func(c candidate) bool { return c.dev.DeviceGroup == id }) {
+		return aliasing.Alias{}, fmt.Errorf("no roku found in group '%s' (use --force to alias it anyway)", id)
+	}
+	return alias, nil
+}
 
-	aliases, err := aliasing.Load(ctx)
+// pick lists the devices on the network and names the one the user chooses.
+func pick(ctx context.Context, cmd *cobra.Command, cfg channel.Cfg, name string) (aliasing.Alias, error) {
+	cands, err := discover(ctx, cfg)
 	if err != nil {
-		return err
+		return aliasing.Alias{}, err
 	}
+	out := cmd.ErrOrStderr()
+	for i, c := range cands {
+		fmt.Fprintf(out, "%d) %s\n", i+1, c)
+	}
+	fmt.Fprintf(out, "device to alias as '%s' [1-%d]: ", name, len(cands))
+	line, err := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+	if err != nil && (!errors.Is(err, io.EOF) || line == "") {
+		return aliasing.Alias{}, errors.New("no device picked")
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line))
+	if err != nil || n < 1 || n > len(cands) {
+		return aliasing.Alias{}, fmt.Errorf("invalid choice '%s'", strings.TrimSpace(line))
+	}
+	return aliasing.Alias{USN: cands[n-1].dev.USN, Name: name}, nil
+}
 
-	aliases = slices.DeleteFunc(aliases, func(a aliasing.Alias) bool {
-		return a.Name == args[0] || a.USN == args[0]
-	})
+func unaliasE(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.New("USN or name required")
+	}
 
-	return aliasing.Save(ctx, aliases)
+	return aliasing.Update(cmd.Context(), func(aliases []aliasing.Alias) ([]aliasing.Alias, error) {
+		return slices.DeleteFunc(aliases, func(a aliasing.Alias) bool {
+			return a.Name == args[0] || a.Target() == args[0] || (a.Group != "" && a.Group == args[0])
+		}), nil
+	})
 }
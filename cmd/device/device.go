@@ -4,7 +4,10 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/dangermike/roku_toy/cmd/device/alias"
+	"github.com/dangermike/roku_toy/cmd/device/forget"
 	"github.com/dangermike/roku_toy/cmd/device/list"
+	"github.com/dangermike/roku_toy/cmd/device/tag"
+	"github.com/dangermike/roku_toy/cmd/device/watch"
 )
 
 func Cmd() *cobra.Command {
@@ -13,7 +16,7 @@ func Cmd() *cobra.Command {
 		Short: "discover and manage Roku devices",
 	}
 
-	cmd.AddCommand(list.Cmd(), alias.Alias(), alias.Unalias())
+	cmd.AddCommand(list.Cmd(), alias.Alias(), alias.Unalias(), tag.Cmd(), forget.Cmd(), watch.Cmd())
 
 	return cmd
 }
@@ -0,0 +1,112 @@
+package forget
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/dangermike/roku_toy/aliasing"
+	"github.com/dangermike/roku_toy/cmd/channel"
+	"github.com/dangermike/roku_toy/inventory"
+	"github.com/dangermike/roku_toy/output"
+	"github.com/spf13/cobra"
+)
+
+func Cmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "forget [<alias|USN>...]",
+		Short: "remove devices from the list of known devices",
+		Long: `Removes devices, given by alias or USN, from the inventory that device list
+uses to show offline devices, along with their aliases. --older-than forgets
+every device that hasn't been seen for that long.`,
+		RunE: forgetE,
+	}
+
+	cmd.Flags().Duration("older-than", 0, "forget every device not seen for this long, e.g. 720h")
+	cmd.Flags().Bool("keep-alias", false, "keep the aliases of forgotten devices")
+
+	return cmd
+}
+
+func forgetE(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	olderThan, err := flags.GetDuration("older-than")
+	if err != nil {
+		return err
+	}
+	keepAlias, err := flags.GetBool("keep-alias")
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 && olderThan <= 0 {
+		return errors.New("devices to forget or --older-than required")
+	}
+
+	ctx := cmd.Context()
+	known, err := inventory.Load(ctx)
+	if err != nil {
+		return err
+	}
+	al, err := aliasing.Load(ctx)
+	if err != nil {
+		return err
+	}
+
+	var forgotten []inventory.Device
+	var errs []error
+	for _, arg := range args {
+		i := slices.IndexFunc(known, func(d inventory.Device) bool {
+			return d.USN == arg || aliasOf(al, d).Name == arg
+		})
+		if i < 0 {
+			errs = append(errs, fmt.Errorf("no known device '%s'", arg))
+			continue
+		}
+		forgotten = append(forgotten, known[i])
+	}
+	if olderThan > 0 {
+		cutoff := time.Now().Add(-olderThan)
+		for _, d := range known {
+			if d.LastSeen.Before(cutoff) {
+				forgotten = append(forgotten, d)
+			}
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	var names []string
+	records := []output.Forgotten{}
+	for _, d := range forgotten {
+		if !slices.ContainsFunc(known, func(k inventory.Device) bool { return k.USN == d.USN }) {
+			continue
+		}
+		known = inventory.Forget(known, d.USN)
+		a := aliasOf(al, d)
+		if a.Name != "" {
+			names = append(names, a.Name)
+		}
+		records = append(records, output.Forgotten{USN: d.USN, Alias: a.Name, Forgotten: true})
+	}
+	if err := inventory.Save(ctx, known); err != nil {
+		return err
+	}
+	if !keepAlias && len(names) > 0 {
+		if err := aliasing.Update(ctx, func(aliases []aliasing.Alias) ([]aliasing.Alias, error) {
+			return slices.DeleteFunc(aliases, func(a aliasing.Alias) bool {
+				return slices.Contains(names, a.Name)
+			}), nil
+		}); err != nil {
+			return err
+		}
+	}
+	return channel.FromContext(ctx).Output.Print(records)
+}
+
+// aliasOf is the alias of d, if it has one.
+func aliasOf(al []aliasing.Alias, d inventory.Device) aliasing.Alias {
+	a, _ := aliasing.ForDevice(al, d.USN, d.Location)
+	return a
+}
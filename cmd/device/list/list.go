@@ -1,51 +1,211 @@
 package list
 
 import (
+	"context"
 	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/dangermike/roku_toy/aliasing"
+	"github.com/dangermike/roku_toy/cmd/channel"
+	"github.com/dangermike/roku_toy/discovery"
+	"github.com/dangermike/roku_toy/inventory"
 	"github.com/dangermike/roku_toy/logging"
+	"github.com/dangermike/roku_toy/output"
 	"github.com/dangermike/roku_toy/roku"
 	"github.com/spf13/cobra"
+	"go.uber.org/zap"
 )
 
+// infoTimeout bounds the device-info request made to update the inventory.
+const infoTimeout = 2 * time.Second
+
 func Cmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "list",
 		Short: "show all local Roku devices",
-		RunE:  listE,
+		Long: `Lists the devices found on the network along with aliased devices seen
+before that did not answer this time, which are shown as offline with the
+time they were last seen.`,
+		RunE: listE,
 	}
 
-	cmd.Flags().BoolP("verbose", "v", false, "verbose logging")
+	channel.AddDiscoveryFlags(cmd.Flags())
+	cmd.Flags().Bool("scan", false, "scan subnets for devices instead of using SSDP (same as --discovery scan)")
+	cmd.Flags().Bool("by-room", false, "group devices under the room set on their alias")
+	cmd.Flags().Bool("online", false, "only show devices that answered")
 
 	return cmd
 }
 
 func listE(cmd *cobra.Command, args []string) error {
-	debug, err := cmd.Flags().GetBool("verbose")
+	ctx := cmd.Context()
+	cfg := channel.FromContext(ctx)
+	scan, err := cmd.Flags().GetBool("scan")
+	if err != nil {
+		return err
+	}
+	byRoom, err := cmd.Flags().GetBool("by-room")
+	if err != nil {
+		return err
+	}
+	onlineOnly, err := cmd.Flags().GetBool("online")
 	if err != nil {
 		return err
 	}
+	if scan {
+		cfg.Discovery = "scan"
+	}
+	// cached devices would stop the chain before everything else is found
+	cfg.Discovery = discovery.Without(cfg.Discovery, "cache")
 
-	ctx := logging.NewContext(cmd.Context(), logging.Configure(debug))
-	aliases := map[string]string{}
 	al, err := aliasing.Load(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to load aliases: %w", err)
 	}
-	for _, a := range al {
-		aliases[a.USN] = a.Name
+
+	disc, err := channel.NewDiscoverer(cfg, al, nil)
+	if err != nil {
+		return err
 	}
 
-	if err := roku.SSDP(ctx, func(dev *roku.Device) error {
-		if alias, ok := aliases[dev.USN]; ok {
-			fmt.Println(dev.USN, dev.Location, alias)
-		} else {
-			fmt.Println(dev.USN, dev.Location)
-		}
+	known, err := inventory.Load(ctx)
+	if err != nil {
+		return err
+	}
+	var online []*roku.Device
+	var devices []output.Device
+	now := time.Now()
+	if err := disc.Discover(ctx, func(dev *roku.Device) error {
+		online = append(online, dev)
+		d, _ := inventory.Find(known, dev.USN)
+		d.USN, d.Location, d.DeviceGroup, d.LastSeen = dev.USN, dev.Location.String(), dev.DeviceGroup, now
+		devices = append(devices, describe(d, al, true))
 		return nil
 	}); err != nil {
 		return fmt.Errorf("failed to discover rokus: %w", err)
 	}
+
+	if !onlineOnly {
+		for _, d := range known {
+			if !slices.ContainsFunc(online, func(dev *roku.Device) bool { return dev.USN == d.USN }) {
+				devices = append(devices, describe(d, al, false))
+			}
+		}
+	}
+
+	if byRoom {
+		err = printRooms(cfg.Output, devices)
+	} else {
+		err = cfg.Output.Print(devices)
+	}
+	if err != nil {
+		return err
+	}
+
+	return record(ctx, known, online, func(dev *roku.Device) bool {
+		_, ok := aliasing.ForDevice(al, dev.USN, dev.Location.String())
+		return ok
+	})
+}
+
+// describe is the record shown for a device.
+func describe(d inventory.Device, al []aliasing.Alias, online bool) output.Device {
+	alias, _ := aliasing.ForDevice(al, d.USN, d.Location)
+	rec := output.Device{
+		USN:      d.USN,
+		Location: d.Location,
+		Alias:    alias.Name,
+		Room:     alias.Room,
+		Tags:     append([]string{}, alias.Tags...),
+		Model:    d.Model,
+		Serial:   d.Serial,
+		MAC:      d.MAC(),
+		Online:   online,
+
+		SoftwareVersion: d.SoftwareVersion,
+	}
+	if d.DeviceGroup != "" {
+		rec.Group = aliasing.GroupName(al, d.DeviceGroup)
+	}
+	if !d.LastSeen.IsZero() {
+		lastSeen := d.LastSeen.Local().Truncate(time.Second)
+		rec.LastSeen = &lastSeen
+	}
+	return rec
+}
+
+// record updates the inventory with the aliased devices that answered,
+// asking each for its device-info.
+func record(ctx context.Context, known []inventory.Device, online []*roku.Device, aliased func(*roku.Device) bool) error {
+	log := logging.FromContext(ctx)
+	online = slices.DeleteFunc(online, func(dev *roku.Device) bool { return !aliased(dev) })
+	if len(online) == 0 {
+		return nil
+	}
+	infos := make([]roku.DeviceInfo, len(online))
+	var wg sync.WaitGroup
+	for i, dev := range online {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, infoTimeout)
+			defer cancel()
+			info, err := dev.QueryDeviceInfo(ctx)
+			if err != nil {
+				log.Debug("failed to get device info", zap.String("usn", dev.USN), zap.Error(err))
+				return
+			}
+			infos[i] = info
+		}()
+	}
+	wg.Wait()
+	now := time.Now()
+	for i, dev := range online {
+		known = inventory.Record(known, dev, infos[i], now)
+	}
+	if err := inventory.Save(ctx, known); err != nil {
+		return fmt.Errorf("failed to save device inventory: %w", err)
+	}
+	return nil
+}
+
+// printRooms lists the devices by room, in order of room name, with devices
+// that have no room last. Tables get a heading and a table for each room;
+// other formats get one list sorted that way.
+func printRooms(p *output.Printer, devices []output.Device) error {
+	slices.SortStableFunc(devices, func(a, b output.Device) int {
+		switch {
+		case a.Room == b.Room:
+			return 0
+		case a.Room == "":
+			return 1
+		case b.Room == "":
+			return -1
+		}
+		return strings.Compare(a.Room, b.Room)
+	})
+	if p.Structured() {
+		return p.Print(devices)
+	}
+	for len(devices) > 0 {
+		room := devices[0].Room
+		n := slices.IndexFunc(devices, func(d output.Device) bool { return d.Room != room })
+		if n < 0 {
+			n = len(devices)
+		}
+		if room == "" {
+			room = "(no room)"
+		}
+		if err := p.Heading(room); err != nil {
+			return err
+		}
+		if err := p.Print(devices[:n]); err != nil {
+			return err
+		}
+		devices = devices[n:]
+	}
 	return nil
 }
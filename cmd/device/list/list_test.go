@@ -0,0 +1,53 @@
+package list
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dangermike/roku_toy/aliasing"
+	"github.com/dangermike/roku_toy/inventory"
+	"github.com/dangermike/roku_toy/output"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDescribeOffline(t *testing.T) {
+	seen := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	d := inventory.Device{
+		USN:             "YH00AA000001",
+		Location:        "http://192.168.1.20:8060/",
+		Model:           "Roku Ultra",
+		Serial:          "YH00AA000001",
+		WifiMAC:         "b0:a7:37:00:00:01",
+		EthernetMAC:     "b0:a7:37:00:00:02",
+		NetworkType:     "wifi",
+		SoftwareVersion: "12.5.0",
+		LastSeen:        seen,
+	}
+	al := []aliasing.Alias{{Name: "den", USN: d.USN}}
+
+	rec := describe(d, al, false)
+	require.False(t, rec.Online)
+	require.Equal(t, "den", rec.Alias)
+	require.Equal(t, "YH00AA000001", rec.Serial)
+	require.Equal(t, "b0:a7:37:00:00:01", rec.MAC)
+	require.Equal(t, "12.5.0", rec.SoftwareVersion)
+	require.NotNil(t, rec.LastSeen)
+	require.True(t, seen.Equal(*rec.LastSeen))
+}
+
+func TestPrintRooms(t *testing.T) {
+	var sb strings.Builder
+	p, err := output.New(&sb, "go-template={{.usn}}")
+	require.NoError(t, err)
+	devices := []output.Device{{USN: "A"}, {USN: "B", Room: "kitchen"}, {USN: "C", Room: "den"}}
+	require.NoError(t, printRooms(p, devices))
+	require.Equal(t, "C\nB\nA\n", sb.String(), "structured formats get one list without headings")
+
+	sb.Reset()
+	p, err = output.New(&sb, "")
+	require.NoError(t, err)
+	require.NoError(t, printRooms(p, []output.Device{{USN: "A"}, {USN: "C", Room: "den"}}))
+	require.Contains(t, sb.String(), "den:\n")
+	require.Contains(t, sb.String(), "\n\n(no room):\n")
+}
@@ -0,0 +1,75 @@
+package tag
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/dangermike/roku_toy/aliasing"
+	"github.com/spf13/cobra"
+)
+
+func Cmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "tag",
+		Short: "add or remove tags on aliased devices",
+		Long: `Tags group devices for commands run with --tag, such as
+"roku_toy power off --tag bedroom".`,
+	}
+
+	cmd.AddCommand(cmdAdd(), cmdRemove())
+
+	return cmd
+}
+
+func cmdAdd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "add <alias> <tag>...",
+		Short: "tag an aliased device",
+		Args:  cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return update(cmd, args[0], func(tags []string) []string {
+				for _, t := range args[1:] {
+					if !slices.ContainsFunc(tags, func(have string) bool { return strings.EqualFold(have, t) }) {
+						tags = append(tags, t)
+					}
+				}
+				return tags
+			})
+		},
+	}
+	return cmd
+}
+
+func cmdRemove() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "remove <alias> <tag>...",
+		Short: "remove tags from an aliased device",
+		Args:  cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return update(cmd, args[0], func(tags []string) []string {
+				return slices.DeleteFunc(tags, func(have string) bool {
+					return slices.ContainsFunc(args[1:], func(t string) bool { return strings.EqualFold(have, t) })
+				})
+			})
+		},
+	}
+	return cmd
+}
+
+// update changes the tags of the alias called name.
+func update(cmd *cobra.Command, name string, fn func([]string) []string) error {
+	return aliasing.Update(cmd.Context(), func(aliases []aliasing.Alias) ([]aliasing.Alias, error) {
+		for i, a := range aliases {
+			if a.Name != name {
+				continue
+			}
+			if a.Group != "" {
+				return nil, fmt.Errorf("'%s' is a group alias; only devices can be tagged", name)
+			}
+			aliases[i].Tags = fn(slices.Clone(a.Tags))
+			return aliases, nil
+		}
+		return nil, fmt.Errorf("no alias named '%s'", name)
+	})
+}
@@ -0,0 +1,86 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dangermike/roku_toy/aliasing"
+	"github.com/dangermike/roku_toy/cmd/channel"
+	"github.com/dangermike/roku_toy/output"
+	"github.com/dangermike/roku_toy/roku"
+	"github.com/spf13/cobra"
+)
+
+func Cmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "watch",
+		Short: "print Roku devices arriving, leaving and changing address",
+		RunE:  watchE,
+	}
+
+	cmd.Flags().Bool("no-search", false, "only listen for announcements instead of searching for devices that are already up")
+	channel.AddDiscoveryFlags(cmd.Flags())
+
+	return cmd
+}
+
+func watchE(cmd *cobra.Command, args []string) error {
+	noSearch, err := cmd.Flags().GetBool("no-search")
+	if err != nil {
+		return err
+	}
+	opts := roku.WatchOptions{Search: !noSearch}
+	if err := channel.ParseDiscoveryFlags(&opts.SSDPOptions, cmd.Flags()); err != nil {
+		return err
+	}
+
+	ctx := cmd.Context()
+	cfg := channel.FromContext(ctx)
+	aliases := map[string]string{}
+	al, err := aliasing.Load(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load aliases: %w", err)
+	}
+	for _, a := range al {
+		if a.USN != "" {
+			aliases[a.USN] = a.Name
+		}
+	}
+
+	err = roku.Watch(ctx, opts, func(ev roku.Event) error {
+		now := time.Now()
+		if cfg.Output.Structured() {
+			rec := output.Event{
+				Time:     now,
+				Type:     ev.Type.String(),
+				USN:      ev.Device.USN,
+				Location: ev.Device.Location.String(),
+				Alias:    aliases[ev.Device.USN],
+				Expired:  ev.Expired,
+			}
+			if ev.Previous != nil {
+				rec.Previous = ev.Previous.String()
+			}
+			return cfg.Output.Stream(rec)
+		}
+		line := []any{now.Format(time.RFC3339), ev.Type, ev.Device.USN}
+		if ev.Type == roku.EventMove {
+			line = append(line, ev.Previous, "->")
+		}
+		line = append(line, ev.Device.Location)
+		if alias, ok := aliases[ev.Device.USN]; ok {
+			line = append(line, alias)
+		}
+		if ev.Expired {
+			line = append(line, "(expired)")
+		}
+		fmt.Println(line...)
+		return nil
+	})
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
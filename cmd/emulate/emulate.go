@@ -0,0 +1,57 @@
+package emulate
+
+import (
+	"github.com/dangermike/roku_toy/emulator"
+	"github.com/dangermike/roku_toy/logging"
+	"github.com/spf13/cobra"
+)
+
+func Cmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "emulate",
+		Short: "pretend to be one or more Roku devices",
+		Long: `Serve the ECP API and answer SSDP searches from in-memory state so
+roku_toy can be used without a real device. Without --file a single
+device with a handful of apps is emulated on port 8060.`,
+		Args: cobra.NoArgs,
+		RunE: emulateE,
+	}
+
+	cmd.Flags().StringP("file", "f", "", "YAML file describing the devices to emulate")
+	cmd.Flags().String("interface", "", "network interface to answer SSDP on (default: the system default)")
+	cmd.Flags().Bool("no-ssdp", false, "do not answer SSDP searches; devices are only reachable by address")
+
+	return cmd
+}
+
+func emulateE(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	path, err := flags.GetString("file")
+	if err != nil {
+		return err
+	}
+	cfg := emulator.DefaultConfig()
+	if path != "" {
+		if cfg, err = emulator.LoadConfig(path); err != nil {
+			return err
+		}
+	}
+	if flags.Changed("interface") {
+		if cfg.Interface, err = flags.GetString("interface"); err != nil {
+			return err
+		}
+	}
+	if flags.Changed("no-ssdp") {
+		if cfg.NoSSDP, err = flags.GetBool("no-ssdp"); err != nil {
+			return err
+		}
+	}
+
+	ctx := cmd.Context()
+	log := logging.FromContext(ctx)
+	emu, err := emulator.New(cfg, log)
+	if err != nil {
+		return err
+	}
+	return emu.Run(ctx)
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/dangermike/roku_toy/config"
+	"github.com/spf13/pflag"
+)
+
+// envPrefix starts the environment variable for each flag in envFlags, e.g.
+// ROKU_TOY_DEVICE for --device.
+const envPrefix = "ROKU_TOY_"
+
+// envFlags are the flags that can be set from the environment: the global
+// ones and the discovery ones that pick a device. Flags of a single command
+// are left alone so a variable meant for one command can't change another.
+var envFlags = []string{"device", "first", "verbose", "output", "discovery", "ip", "config"}
+
+func envName(flag string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flag, "-", "_"))
+}
+
+// applyEnv sets each flag in envFlags that the command has but that wasn't
+// given on the command line from its environment variable, if that is set.
+// --device takes a single device.
+func applyEnv(flags *pflag.FlagSet) error {
+	var errs []error
+	for _, name := range envFlags {
+		f := flags.Lookup(name)
+		if f == nil || f.Changed {
+			continue
+		}
+		val, ok := os.LookupEnv(envName(name))
+		if !ok {
+			continue
+		}
+		if err := flags.Set(name, val); err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s: %w", envName(name), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// configDefaults are the flags whose default can be set in the config file,
+// and where.
+var configDefaults = map[string]func(*config.Config) string{
+	"discovery": func(c *config.Config) string { return c.Discovery },
+	"ip":        func(c *config.Config) string { return c.IP },
+}
+
+// applyConfig sets the flags in configDefaults that the command has but
+// neither the command line nor the environment set from the config file.
+// It runs after applyEnv, so the environment wins over the config file.
+func applyConfig(ctx context.Context, flags *pflag.FlagSet) error {
+	var unset []string
+	for name := range configDefaults {
+		if f := flags.Lookup(name); f != nil && !f.Changed {
+			unset = append(unset, name)
+		}
+	}
+	if len(unset) == 0 {
+		return nil
+	}
+	conf, err := config.Load(ctx)
+	if err != nil {
+		return err
+	}
+	slices.Sort(unset)
+	var errs []error
+	for _, name := range unset {
+		val := configDefaults[name](conf)
+		if val == "" {
+			continue
+		}
+		if err := flags.Set(name, val); err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s in config: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
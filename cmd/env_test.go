@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dangermike/roku_toy/config"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyEnv(t *testing.T) {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.BoolP("first", "1", false, "")
+	flags.StringArrayP("device", "d", nil, "")
+	flags.String("discovery", "ssdp", "")
+	flags.BoolP("verbose", "v", false, "")
+	require.NoError(t, flags.Parse([]string{"-d", "den"}))
+
+	t.Setenv("ROKU_TOY_FIRST", "true")
+	t.Setenv("ROKU_TOY_DEVICE", "ignored")
+	t.Setenv("ROKU_TOY_DISCOVERY", "cache,ssdp")
+	require.NoError(t, applyEnv(flags))
+
+	first, _ := flags.GetBool("first")
+	require.True(t, first)
+	devices, _ := flags.GetStringArray("device")
+	require.Equal(t, []string{"den"}, devices, "the command line wins")
+	discovery, _ := flags.GetString("discovery")
+	require.Equal(t, "cache,ssdp", discovery)
+	verbose, _ := flags.GetBool("verbose")
+	require.False(t, verbose)
+
+	t.Setenv("ROKU_TOY_VERBOSE", "loud")
+	require.ErrorContains(t, applyEnv(flags), "invalid ROKU_TOY_VERBOSE")
+}
+
+func TestApplyEnvCommandFlags(t *testing.T) {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.Duration("discovery-timeout", 2*time.Second, "")
+	flags.DurationP("timeout", "t", 10*time.Second, "")
+	flags.Bool("select", false, "")
+	require.NoError(t, flags.Parse(nil))
+
+	t.Setenv("ROKU_TOY_DISCOVERY_TIMEOUT", "5s")
+	t.Setenv("ROKU_TOY_TIMEOUT", "1s")
+	t.Setenv("ROKU_TOY_SELECT", "true")
+	require.NoError(t, applyEnv(flags))
+
+	timeout, _ := flags.GetDuration("discovery-timeout")
+	require.Equal(t, 2*time.Second, timeout)
+	timeout, _ = flags.GetDuration("timeout")
+	require.Equal(t, 10*time.Second, timeout, "command flags are not read from the environment")
+	sel, _ := flags.GetBool("select")
+	require.False(t, sel)
+}
+
+func TestApplyConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("discovery: cache,ssdp\nip: ipv6\n"), 0o644))
+	config.SetPath(path)
+	defer config.SetPath("")
+	ctx := context.Background()
+
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.String("discovery", "ssdp", "")
+	flags.String("ip", "prefer-ipv4", "")
+	require.NoError(t, flags.Parse(nil))
+	require.NoError(t, applyConfig(ctx, flags))
+	discovery, _ := flags.GetString("discovery")
+	require.Equal(t, "cache,ssdp", discovery)
+	ip, _ := flags.GetString("ip")
+	require.Equal(t, "ipv6", ip)
+
+	flags = pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.String("discovery", "ssdp", "")
+	flags.String("ip", "prefer-ipv4", "")
+	require.NoError(t, flags.Parse([]string{"--discovery", "scan"}))
+	t.Setenv("ROKU_TOY_IP", "ipv4")
+	require.NoError(t, applyEnv(flags))
+	require.NoError(t, applyConfig(ctx, flags))
+	discovery, _ = flags.GetString("discovery")
+	require.Equal(t, "scan", discovery, "the command line wins")
+	ip, _ = flags.GetString("ip")
+	require.Equal(t, "ipv4", ip, "the environment wins")
+
+	// commands without the flags don't read the config
+	require.NoError(t, os.WriteFile(path, []byte("ip: ipv5\n"), 0o644))
+	require.NoError(t, applyConfig(ctx, pflag.NewFlagSet("test", pflag.ContinueOnError)))
+	flags.Lookup("ip").Changed = false
+	require.ErrorContains(t, applyConfig(ctx, flags), "unknown IP mode 'ipv5'")
+}
@@ -0,0 +1,36 @@
+package key
+
+import (
+	"context"
+	"errors"
+
+	"github.com/dangermike/roku_toy/cmd/channel"
+	"github.com/dangermike/roku_toy/roku"
+	"github.com/spf13/cobra"
+)
+
+func Cmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "key <key>...",
+		Short: "send keypresses, e.g. home, up, select or Lit_a",
+		RunE:  keyE,
+	}
+	channel.AddDiscoveryFlags(cmd.Flags())
+	channel.AddFanOutFlags(cmd.Flags())
+	return cmd
+}
+
+func keyE(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		return errors.New("at least one key required")
+	}
+	ctx := cmd.Context()
+	return channel.ForEach(ctx, channel.FromContext(ctx), func(ctx context.Context, device *roku.Device) (any, error) {
+		for _, key := range args {
+			if err := device.Keypress(ctx, key); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	})
+}
@@ -0,0 +1,53 @@
+package power
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dangermike/roku_toy/cmd/channel"
+	"github.com/dangermike/roku_toy/output"
+	"github.com/dangermike/roku_toy/roku"
+	"github.com/spf13/cobra"
+)
+
+// keys maps the power command's arguments to ECP keys.
+var keys = map[string]string{
+	"on":     "PowerOn",
+	"off":    "PowerOff",
+	"toggle": "Power",
+}
+
+func Cmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:       "power [on|off|toggle]",
+		Short:     "show or change the power state",
+		Long:      "Without an argument the power mode from device-info (e.g. PowerOn, DisplayOff) is shown.",
+		Args:      cobra.MaximumNArgs(1),
+		ValidArgs: []string{"on", "off", "toggle"},
+		RunE:      powerE,
+	}
+	channel.AddDiscoveryFlags(cmd.Flags())
+	channel.AddFanOutFlags(cmd.Flags())
+	return cmd
+}
+
+func powerE(cmd *cobra.Command, args []string) error {
+	var key string
+	if len(args) == 1 {
+		var ok bool
+		if key, ok = keys[args[0]]; !ok {
+			return fmt.Errorf("unknown power state '%s' (want on, off or toggle)", args[0])
+		}
+	}
+	ctx := cmd.Context()
+	return channel.ForEach(ctx, channel.FromContext(ctx), func(ctx context.Context, device *roku.Device) (any, error) {
+		if key != "" {
+			return nil, device.Keypress(ctx, key)
+		}
+		info, err := device.QueryDeviceInfo(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return output.Power{PowerMode: info.PowerMode}, nil
+	})
+}
@@ -0,0 +1,66 @@
+package remote
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/dangermike/roku_toy/aliasing"
+	"github.com/dangermike/roku_toy/cmd/channel"
+	"github.com/dangermike/roku_toy/config"
+	"github.com/dangermike/roku_toy/remote"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+func Cmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "remote",
+		Short: "control a device from the terminal like a remote",
+		Long: `Takes over the terminal and sends keys to the device as they are pressed,
+while showing its power state, the active channel and what is playing. Press
+t to type text into the device and esc to stop, and q or ctrl+c to quit. Key
+bindings can be changed under remote.keys in the config file.`,
+		Args: cobra.NoArgs,
+		RunE: remoteE,
+	}
+	channel.AddDiscoveryFlags(cmd.Flags())
+	cmd.Flags().Duration("refresh", remote.DefaultRefresh, "how often the status is updated")
+	return cmd
+}
+
+func remoteE(cmd *cobra.Command, args []string) error {
+	refresh, err := cmd.Flags().GetDuration("refresh")
+	if err != nil {
+		return err
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return errors.New("remote needs a terminal")
+	}
+	ctx := cmd.Context()
+	conf, err := config.Load(ctx)
+	if err != nil {
+		return err
+	}
+	keys, err := remote.Bindings(conf.Remote.Keys)
+	if err != nil {
+		return fmt.Errorf("invalid remote keys in config: %w", err)
+	}
+	dev, err := channel.GetDevice(ctx, channel.FromContext(ctx))
+	if err != nil {
+		return err
+	}
+	name := dev.USN
+	if a, ok := aliasing.ForDevice(aliasing.FromConfig(conf), dev.USN, dev.Location.String()); ok {
+		name = a.Name
+	}
+
+	state, err := term.MakeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		return fmt.Errorf("failed to set up terminal: %w", err)
+	}
+	defer term.Restore(int(os.Stdin.Fd()), state)
+
+	r := &remote.Remote{Device: dev, Name: name, Keys: keys, Refresh: refresh}
+	return r.Run(ctx, os.Stdin, os.Stdout)
+}
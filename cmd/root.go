@@ -4,13 +4,60 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/dangermike/roku_toy/cmd/channel"
+	"github.com/dangermike/roku_toy/cmd/completion"
+	"github.com/dangermike/roku_toy/cmd/dev"
 	"github.com/dangermike/roku_toy/cmd/device"
+	"github.com/dangermike/roku_toy/cmd/emulate"
+	"github.com/dangermike/roku_toy/cmd/key"
+	"github.com/dangermike/roku_toy/cmd/power"
+	"github.com/dangermike/roku_toy/cmd/remote"
+	"github.com/dangermike/roku_toy/cmd/ui"
+	"github.com/dangermike/roku_toy/config"
+	"github.com/dangermike/roku_toy/logging"
+	"github.com/dangermike/roku_toy/output"
 )
 
 func Cmd() *cobra.Command {
-	cmd := &cobra.Command{}
+	cmd := &cobra.Command{
+		Use:               "roku_toy",
+		PersistentPreRunE: preRunE,
+		// replaced by the completion command
+		CompletionOptions: cobra.CompletionOptions{DisableDefaultCmd: true},
+		// main prints the error
+		SilenceErrors: true,
+	}
 
-	cmd.AddCommand(device.Cmd(), channel.Cmd())
+	cmd.PersistentFlags().String("config", "", "config file (default $XDG_CONFIG_HOME/roku_toy/config.yaml)")
+	cmd.PersistentFlags().StringP("output", "o", output.Table, output.Usage)
+	channel.AddFlags(cmd.PersistentFlags())
+
+	cmd.AddCommand(device.Cmd(), channel.Cmd(), ui.Cmd(), dev.Cmd(), emulate.Cmd(), key.Cmd(), power.Cmd(), remote.Cmd(), completion.Cmd())
+	channel.RegisterCompletions(cmd)
 
 	return cmd
 }
+
+func preRunE(cmd *cobra.Command, args []string) error {
+	if err := applyEnv(cmd.Flags()); err != nil {
+		return err
+	}
+	path, err := cmd.Flags().GetString("config")
+	if err != nil {
+		return err
+	}
+	config.SetPath(path)
+	if err := applyConfig(cmd.Context(), cmd.Flags()); err != nil {
+		// a broken config file is not a usage error
+		cmd.SilenceUsage = true
+		return err
+	}
+	cfg, err := channel.ParseFlags(cmd.Flags())
+	if err != nil {
+		return err
+	}
+	// the command line is fine, so errors from here on are not usage errors
+	cmd.SilenceUsage = true
+	ctx := logging.NewContext(cmd.Context(), logging.Configure(cfg.Debug))
+	cmd.SetContext(channel.NewContext(ctx, cfg))
+	return nil
+}
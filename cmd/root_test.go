@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"io"
+	"testing"
+
+	"github.com/dangermike/roku_toy/cmd/channel"
+	"github.com/dangermike/roku_toy/output"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreRun(t *testing.T) {
+	var got channel.Cfg
+	root := Cmd()
+	root.SetOut(io.Discard)
+	root.SetErr(io.Discard)
+	probe := &cobra.Command{
+		Use: "probe",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			got = channel.FromContext(cmd.Context())
+			return nil
+		},
+	}
+	channel.AddDiscoveryFlags(probe.Flags())
+	root.AddCommand(probe)
+
+	root.SetArgs([]string{"probe", "-d", "den", "-d", "office", "--tag", "night", "-o", "json", "--discovery", "ssdp"})
+	require.NoError(t, root.Execute())
+	require.Equal(t, []string{"den", "office"}, got.Devices)
+	require.Equal(t, []string{"night"}, got.Tags)
+	require.Equal(t, "ssdp", got.Discovery)
+	require.Equal(t, output.JSON, got.Output.Format())
+
+	root.SetArgs([]string{"probe", "-o", "xml"})
+	require.ErrorContains(t, root.Execute(), "unknown output format 'xml'")
+}
@@ -0,0 +1,126 @@
+package ui
+
+import (
+	"errors"
+	"time"
+
+	"github.com/dangermike/roku_toy/cmd/channel"
+	"github.com/dangermike/roku_toy/output"
+	"github.com/dangermike/roku_toy/uiauto"
+	"github.com/spf13/cobra"
+)
+
+func Cmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "ui",
+		Short: "inspect and drive channel UIs (dev mode only)",
+	}
+
+	cmd.AddCommand(cmdWait(), cmdNavigate(), cmdFocused())
+
+	return cmd
+}
+
+func cmdWait() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "wait <selector>",
+		Short: "Wait until an element matching the selector is on screen",
+		RunE:  waitE,
+	}
+	channel.AddDiscoveryFlags(cmd.Flags())
+	cmd.Flags().DurationP("timeout", "t", 10*time.Second, "how long to wait")
+	return cmd
+}
+
+func cmdNavigate() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "navigate <selector>",
+		Short: "Move focus to the element matching the selector",
+		RunE:  navigateE,
+	}
+	channel.AddDiscoveryFlags(cmd.Flags())
+	cmd.Flags().Bool("select", false, "press select once the element has focus")
+	cmd.Flags().Int("max-steps", 100, "maximum number of keypresses to send")
+	return cmd
+}
+
+func cmdFocused() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "focused",
+		Short: "Show the element that currently has focus",
+		RunE:  focusedE,
+	}
+	channel.AddDiscoveryFlags(cmd.Flags())
+	return cmd
+}
+
+func waitE(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.New("selector required")
+	}
+	timeout, err := cmd.Flags().GetDuration("timeout")
+	if err != nil {
+		return err
+	}
+	ctx := cmd.Context()
+	cfg := channel.FromContext(ctx)
+	device, err := channel.GetDevice(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	node, err := uiauto.New(device).WaitFor(ctx, args[0], timeout)
+	if err != nil {
+		return err
+	}
+	return cfg.Output.Print(output.NewNode(node))
+}
+
+func navigateE(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.New("selector required")
+	}
+	pressSelect, err := cmd.Flags().GetBool("select")
+	if err != nil {
+		return err
+	}
+	maxSteps, err := cmd.Flags().GetInt("max-steps")
+	if err != nil {
+		return err
+	}
+	ctx := cmd.Context()
+	cfg := channel.FromContext(ctx)
+	device, err := channel.GetDevice(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	drv := uiauto.New(device)
+	drv.MaxSteps = maxSteps
+	node, err := drv.NavigateTo(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	if err := cfg.Output.Print(output.NewNode(node)); err != nil {
+		return err
+	}
+	if pressSelect {
+		return device.Keypress(ctx, "select")
+	}
+	return nil
+}
+
+func focusedE(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	cfg := channel.FromContext(ctx)
+	device, err := channel.GetDevice(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	node, err := uiauto.New(device).Focused(ctx)
+	if err != nil {
+		return err
+	}
+	return cfg.Output.Print(output.NewNode(node))
+}
@@ -0,0 +1,318 @@
+// Package config reads and writes the roku_toy config file,
+// $XDG_CONFIG_HOME/roku_toy/config.yaml. The file is versioned so later releases
+// can change its layout, and it replaces the comma-separated aliases file of
+// earlier releases, which is migrated the first time the config is loaded.
+package config
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dangermike/roku_toy/logging"
+	"github.com/dangermike/roku_toy/roku"
+	"github.com/dangermike/roku_toy/xdg"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// CurrentVersion is the layout version written by this release.
+const CurrentVersion = 1
+
+const fileName = "config.yaml"
+
+type Config struct {
+	Version int `yaml:"version"`
+	// Discovery and IP are the defaults of the --discovery and --ip flags.
+	Discovery string       `yaml:"discovery,omitempty"`
+	IP        string       `yaml:"ip,omitempty"`
+	Aliases   []AliasEntry `yaml:"aliases,omitempty"`
+	Shortcuts []Shortcut   `yaml:"shortcuts,omitempty"`
+	Remote    Remote       `yaml:"remote,omitempty"`
+}
+
+// AliasEntry is an alias as stored in the config file. Exactly one of USN,
+// Address and Group is set.
+type AliasEntry struct {
+	Name    string `yaml:"name"`
+	USN     string `yaml:"usn,omitempty"`
+	Address string `yaml:"address,omitempty"`
+	Group   string `yaml:"group,omitempty"`
+
+	Room  string   `yaml:"room,omitempty"`
+	Tags  []string `yaml:"tags,omitempty"`
+	Notes string   `yaml:"notes,omitempty"`
+
+	// Shortcuts apply to this device only.
+	Shortcuts []Shortcut `yaml:"shortcuts,omitempty"`
+
+	// line is where the entry was read from, for error messages.
+	line int
+}
+
+func (e *AliasEntry) UnmarshalYAML(n *yaml.Node) error {
+	type plain AliasEntry
+	if err := n.Decode((*plain)(e)); err != nil {
+		return err
+	}
+	e.line = n.Line
+	return nil
+}
+
+func (e AliasEntry) validate() error {
+	var targets int
+	for _, t := range []string{e.USN, e.Address, e.Group} {
+		if t != "" {
+			targets++
+		}
+	}
+	switch {
+	case strings.TrimSpace(e.Name) == "":
+		return errors.New("alias has no name")
+	case strings.ContainsAny(e.Name, "\r\n"):
+		return fmt.Errorf("alias name %q contains a line break", e.Name)
+	case targets != 1:
+		return fmt.Errorf("alias '%s' needs exactly one of usn, address or group", e.Name)
+	}
+	for _, tag := range e.Tags {
+		if strings.TrimSpace(tag) == "" || strings.Contains(tag, ",") {
+			return fmt.Errorf("alias '%s' has an invalid tag %q", e.Name, tag)
+		}
+	}
+	return nil
+}
+
+func (c *Config) validate() error {
+	if c.Version > CurrentVersion {
+		return fmt.Errorf("config version %d is newer than this roku_toy supports (%d)", c.Version, CurrentVersion)
+	}
+	if c.Version < 1 {
+		return fmt.Errorf("config version %d is invalid", c.Version)
+	}
+	var errs []error
+	if c.IP != "" {
+		if _, err := roku.ParseIPMode(c.IP); err != nil {
+			errs = append(errs, fmt.Errorf("ip: %w", err))
+		}
+	}
+	for i, e := range c.Aliases {
+		if err := e.validate(); err != nil {
+			if e.line > 0 {
+				err = fmt.Errorf("line %d: %w", e.line, err)
+			}
+			errs = append(errs, fmt.Errorf("aliases[%d]: %w", i, err))
+		}
+		if err := validateShortcuts(e.Shortcuts); err != nil {
+			errs = append(errs, fmt.Errorf("aliases[%d]: %w", i, err))
+		}
+	}
+	errs = append(errs, validateShortcuts(c.Shortcuts), c.Remote.validate())
+	return errors.Join(errs...)
+}
+
+// Parse decodes and validates a config file. Unknown fields are errors so
+// typos don't go unnoticed. A file without a version is taken to be
+// version 1.
+func Parse(data []byte) (*Config, error) {
+	cfg := &Config{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if cfg.Version == 0 {
+		cfg.Version = CurrentVersion
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) Marshal() ([]byte, error) {
+	c.Version = CurrentVersion
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(c); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// path overrides the config file location when set.
+var path string
+
+// SetPath makes Load and Save use the config file at p instead of the
+// default location. The legacy aliases file is then looked for next to it
+// as well as in ~/.config/roku_toy.
+func SetPath(p string) {
+	path = p
+}
+
+// Dir is the directory holding the config file.
+func Dir() (string, error) {
+	if path != "" {
+		return filepath.Dir(path), nil
+	}
+	return xdg.ConfigHome()
+}
+
+// Path is the config file, $XDG_CONFIG_HOME/roku_toy/config.yaml unless
+// changed with SetPath.
+func Path() (string, error) {
+	if path != "" {
+		return path, nil
+	}
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, fileName), nil
+}
+
+// Load reads the config file. If there is none yet, aliases from the legacy
+// aliases file are migrated into a new one; if there is neither, or no home
+// directory to look in, an empty config is returned. Nothing is created
+// unless there is something to migrate, so a read-only or missing config
+// directory is fine.
+func Load(ctx context.Context) (*Config, error) {
+	log := logging.FromContext(ctx)
+	targetPath, err := Path()
+	if err != nil {
+		log.Debug("no config location", zap.Error(err))
+		return &Config{Version: CurrentVersion}, nil
+	}
+	cfg, err := read(targetPath)
+	if !errors.Is(err, os.ErrNotExist) {
+		return cfg, err
+	}
+	if _, ok := findLegacy(); !ok {
+		log.Debug("no config file", zap.String("path", targetPath))
+		return &Config{Version: CurrentVersion}, nil
+	}
+	// migrating writes the config file, so it has to hold the lock
+	err = withLock(ctx, func() error {
+		cfg, err = load(ctx)
+		return err
+	})
+	return cfg, err
+}
+
+// Save writes cfg as the current version.
+func Save(ctx context.Context, cfg *Config) error {
+	return withLock(ctx, func() error {
+		return write(ctx, cfg)
+	})
+}
+
+// Update loads the config, lets fn change it and saves the result. Other
+// roku_toy processes are kept from changing the config in the meantime.
+func Update(ctx context.Context, fn func(*Config) error) error {
+	return withLock(ctx, func() error {
+		cfg, err := load(ctx)
+		if err != nil {
+			return err
+		}
+		if err := fn(cfg); err != nil {
+			return err
+		}
+		return write(ctx, cfg)
+	})
+}
+
+// withLock runs fn holding the lock on the config file. The lock is a
+// separate file since the config itself is replaced on every write.
+func withLock(ctx context.Context, fn func() error) error {
+	targetPath, err := Path()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0o755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	unlock, err := lock(ctx, targetPath+".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return fn()
+}
+
+// read parses the config file at targetPath. The error wraps
+// os.ErrNotExist if there is no such file.
+func read(targetPath string) (*Config, error) {
+	data, err := os.ReadFile(targetPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	cfg, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", targetPath, err)
+	}
+	return cfg, nil
+}
+
+// load is Load for callers holding the lock.
+func load(ctx context.Context) (*Config, error) {
+	targetPath, err := Path()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := read(targetPath)
+	if errors.Is(err, os.ErrNotExist) {
+		logging.FromContext(ctx).Debug("no config file", zap.String("path", targetPath))
+		return migrate(ctx)
+	}
+	return cfg, err
+}
+
+// write replaces the config file with cfg. It is written to a temporary file
+// first and renamed into place, so the file is never seen half written.
+// Callers must hold the lock.
+func write(ctx context.Context, cfg *Config) error {
+	targetPath, err := Path()
+	if err != nil {
+		return err
+	}
+	data, err := cfg.Marshal()
+	if err != nil {
+		return fmt.Errorf("refusing to save invalid config: %w", err)
+	}
+	f, err := os.CreateTemp(filepath.Dir(targetPath), "."+filepath.Base(targetPath)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	if err := f.Chmod(0o644); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	if err := os.Rename(f.Name(), targetPath); err != nil {
+		return fmt.Errorf("failed to replace config: %w", err)
+	}
+	logging.FromContext(ctx).Debug("saved config", zap.String("path", targetPath))
+	return nil
+}
@@ -0,0 +1,245 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dangermike/roku_toy/logging"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestParse(t *testing.T) {
+	cfg, err := Parse([]byte(`
+version: 1
+discovery: cache,ssdp
+ip: prefer-ipv6
+aliases:
+  - name: den, upstairs
+    usn: ABCDEFGHIJKL
+  - name: garage
+    address: 192.168.1.9
+  - name: lobby
+    group: 2F6E1C0A0B3D
+`))
+	require.NoError(t, err)
+	require.Len(t, cfg.Aliases, 3)
+	require.Equal(t, "den, upstairs", cfg.Aliases[0].Name)
+	require.Equal(t, "192.168.1.9", cfg.Aliases[1].Address)
+	require.Equal(t, "cache,ssdp", cfg.Discovery)
+	require.Equal(t, "prefer-ipv6", cfg.IP)
+
+	cfg, err = Parse(nil)
+	require.NoError(t, err)
+	require.Equal(t, CurrentVersion, cfg.Version)
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, test := range []struct {
+		name, doc, err string
+	}{
+		{"newer", "version: 2\n", "newer than this roku_toy supports"},
+		{"unknown field", "version: 1\naliasses: []\n", "field aliasses not found"},
+		{"no target", "aliases:\n  - name: den\n", "line 2: alias 'den' needs exactly one of usn, address or group"},
+		{"two targets", "aliases:\n  - name: den\n    usn: A\n    address: 10.0.0.1\n", "exactly one"},
+		{"no name", "aliases:\n  - usn: A\n", "aliases[0]: line 2: alias has no name"},
+		{"line break", "aliases:\n  - name: \"a\\nb\"\n    usn: A\n", "line break"},
+		{"shortcut without app", "shortcuts:\n  - name: news\n", "line 2: shortcut 'news' needs exactly one of app or app_name"},
+		{"duplicate shortcut", "shortcuts:\n  - {name: news, app: \"12\"}\n  - {name: News, app: \"13\"}\n", "shortcuts[1]: line 3: shortcut 'News' is defined twice"},
+		{"remote key", "remote:\n  keys:\n    h: \"Home/1\"\n", "remote: 'h' is bound to an invalid ECP key"},
+		{"ip", "ip: ipv5\n", "ip: unknown IP mode 'ipv5'"},
+		{"alias shortcut", "aliases:\n  - name: den\n    usn: A\n    shortcuts:\n      - name: kids\n", "aliases[0]: shortcuts[0]: line 5"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Parse([]byte(test.doc))
+			require.ErrorContains(t, err, test.err)
+		})
+	}
+}
+
+func TestParseLegacy(t *testing.T) {
+	aliases, err := parseLegacy(strings.NewReader("ABCDEFGHIJKL,den\n\n192.168.1.9,garage\ngroup:2F6E,lobby\nXYZ,name,with,commas\n"))
+	require.NoError(t, err)
+	require.Equal(t, []AliasEntry{
+		{Name: "den", USN: "ABCDEFGHIJKL", line: 1},
+		{Name: "garage", Address: "192.168.1.9", line: 3},
+		{Name: "lobby", Group: "2F6E", line: 4},
+		{Name: "name,with,commas", USN: "XYZ", line: 5},
+	}, aliases)
+
+	_, err = parseLegacy(strings.NewReader("ABCDEFGHIJKL,den\nno-comma\n,empty\n"))
+	require.ErrorContains(t, err, "line 2: expected <USN or address>,<name>")
+	require.ErrorContains(t, err, "line 3:")
+}
+
+func TestMigrate(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", "")
+	ctx := logging.NewContext(context.Background(), zap.NewNop())
+	dir, err := Dir()
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "aliases"), []byte("ABCDEFGHIJKL,den\n"), 0o644))
+
+	cfg, err := Load(ctx)
+	require.NoError(t, err)
+	require.Len(t, cfg.Aliases, 1)
+	require.FileExists(t, filepath.Join(dir, "config.yaml"))
+	require.FileExists(t, filepath.Join(dir, "aliases.bak"))
+	require.NoFileExists(t, filepath.Join(dir, "aliases"))
+
+	require.NoError(t, Update(ctx, func(c *Config) error {
+		c.Aliases = append(c.Aliases, AliasEntry{Name: "garage", Address: "192.168.1.9"})
+		return nil
+	}))
+	cfg, err = Load(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "garage", cfg.Aliases[1].Name)
+
+	require.Error(t, Update(ctx, func(c *Config) error {
+		c.Aliases = append(c.Aliases, AliasEntry{Name: "broken"})
+		return nil
+	}), "invalid configs are not saved")
+}
+
+func TestMigrateFromHome(t *testing.T) {
+	ctx := logging.NewContext(context.Background(), zap.NewNop())
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	legacyDir := filepath.Join(home, ".config", "roku_toy")
+	require.NoError(t, os.MkdirAll(legacyDir, 0o755))
+	legacy := filepath.Join(legacyDir, "aliases")
+
+	for name, setup := range map[string]func() string{
+		"XDG_CONFIG_HOME": func() string {
+			xdg := t.TempDir()
+			t.Setenv("XDG_CONFIG_HOME", xdg)
+			return filepath.Join(xdg, "roku_toy", "config.yaml")
+		},
+		"--config": func() string {
+			t.Setenv("XDG_CONFIG_HOME", "")
+			p := filepath.Join(t.TempDir(), "roku.yaml")
+			SetPath(p)
+			return p
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer SetPath("")
+			require.NoError(t, os.WriteFile(legacy, []byte("ABCDEFGHIJKL,den\n"), 0o644))
+			configPath := setup()
+
+			cfg, err := Load(ctx)
+			require.NoError(t, err)
+			require.Len(t, cfg.Aliases, 1)
+			require.FileExists(t, configPath)
+			require.FileExists(t, legacy+".bak")
+			require.NoFileExists(t, legacy)
+		})
+	}
+}
+
+func TestLoadMissing(t *testing.T) {
+	ctx := logging.NewContext(context.Background(), zap.NewNop())
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	cfg, err := Load(ctx)
+	require.NoError(t, err)
+	require.Equal(t, &Config{Version: CurrentVersion}, cfg)
+	entries, err := os.ReadDir(home)
+	require.NoError(t, err)
+	require.Empty(t, entries, "loading creates nothing")
+
+	// CI runners and containers may have no home directory at all
+	t.Setenv("HOME", "")
+	cfg, err = Load(ctx)
+	require.NoError(t, err)
+	require.Empty(t, cfg.Aliases)
+}
+
+func TestSetPath(t *testing.T) {
+	ctx := logging.NewContext(context.Background(), zap.NewNop())
+	p := filepath.Join(t.TempDir(), "ci", "roku.yaml")
+	SetPath(p)
+	defer SetPath("")
+
+	require.NoError(t, Update(ctx, func(c *Config) error {
+		c.Aliases = []AliasEntry{{Name: "den", USN: "A"}}
+		return nil
+	}))
+	require.FileExists(t, p)
+}
+
+func TestConcurrentUpdate(t *testing.T) {
+	ctx := logging.NewContext(context.Background(), zap.NewNop())
+	dir := t.TempDir()
+	SetPath(filepath.Join(dir, "config.yaml"))
+	defer SetPath("")
+
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			require.NoError(t, Update(ctx, func(c *Config) error {
+				c.Aliases = append(c.Aliases, AliasEntry{Name: fmt.Sprint("tv", i), USN: fmt.Sprint("USN", i)})
+				return nil
+			}))
+		}()
+	}
+	wg.Wait()
+
+	cfg, err := Load(ctx)
+	require.NoError(t, err)
+	require.Len(t, cfg.Aliases, 20, "no update was lost")
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	require.ElementsMatch(t, []string{"config.yaml", "config.yaml.lock"}, names, "no temporary files are left behind")
+}
+
+func TestShortcutsFor(t *testing.T) {
+	cfg, err := Parse([]byte(`
+shortcuts:
+  - name: news
+    app: "12"
+    params: {contentId: X}
+  - name: kids
+    app_name: PBS KIDS
+aliases:
+  - name: den
+    usn: A
+    shortcuts:
+      - name: Kids
+        app: "23353"
+  - name: office
+    usn: B
+`))
+	require.NoError(t, err)
+
+	names := func(shortcuts []Shortcut) []string {
+		var names []string
+		for _, s := range shortcuts {
+			names = append(names, s.Name+"="+s.App+s.AppName)
+		}
+		return names
+	}
+	require.Equal(t, []string{"kids=PBS KIDS", "news=12"}, names(cfg.ShortcutsFor("")))
+	require.Equal(t, []string{"kids=PBS KIDS", "news=12"}, names(cfg.ShortcutsFor("office")))
+	require.Equal(t, []string{"Kids=23353", "news=12"}, names(cfg.ShortcutsFor("den")), "per-device shortcuts win")
+
+	s, ok := cfg.Shortcut("den", "NEWS")
+	require.True(t, ok)
+	require.Equal(t, map[string]string{"contentId": "X"}, s.Params)
+	_, ok = cfg.Shortcut("den", "sports")
+	require.False(t, ok)
+}
@@ -0,0 +1,102 @@
+package config
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dangermike/roku_toy/logging"
+	"github.com/dangermike/roku_toy/roku"
+	"go.uber.org/zap"
+)
+
+const (
+	legacyFileName = "aliases"
+	// legacyGroupPrefix marks group aliases in the legacy file.
+	legacyGroupPrefix = "group:"
+)
+
+// migrate turns the legacy aliases file into a config file. The legacy file
+// is kept as aliases.bak so the migration only happens once. Callers must
+// hold the lock.
+func migrate(ctx context.Context) (*Config, error) {
+	log := logging.FromContext(ctx)
+	legacyPath, ok := findLegacy()
+	if !ok {
+		return &Config{Version: CurrentVersion}, nil
+	}
+	f, err := os.Open(legacyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read legacy aliases: %w", err)
+	}
+	defer f.Close()
+
+	aliases, err := parseLegacy(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to migrate %s: %w", legacyPath, err)
+	}
+	cfg := &Config{Version: CurrentVersion, Aliases: aliases}
+	if err := write(ctx, cfg); err != nil {
+		return nil, fmt.Errorf("failed to migrate %s: %w", legacyPath, err)
+	}
+	if err := os.Rename(legacyPath, legacyPath+".bak"); err != nil {
+		log.Debug("failed to move legacy aliases out of the way", zap.Error(err))
+	}
+	log.Info("migrated legacy aliases", zap.String("from", legacyPath), zap.Int("aliases", len(aliases)))
+	return cfg, nil
+}
+
+// findLegacy returns the legacy aliases file, if there is one. It is looked
+// for next to the config file and where earlier releases always wrote it,
+// ~/.config/roku_toy/aliases, whatever XDG_CONFIG_HOME or --config say.
+func findLegacy() (string, bool) {
+	var candidates []string
+	if dir, err := Dir(); err == nil {
+		candidates = append(candidates, filepath.Join(dir, legacyFileName))
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		candidates = append(candidates, filepath.Join(home, ".config", "roku_toy", legacyFileName))
+	}
+	for _, legacyPath := range candidates {
+		if _, err := os.Stat(legacyPath); err == nil {
+			return legacyPath, true
+		}
+	}
+	return "", false
+}
+
+// parseLegacy reads the "<target>,<name>" lines of the legacy aliases file.
+func parseLegacy(r io.Reader) ([]AliasEntry, error) {
+	scn := bufio.NewScanner(r)
+	var aliases []AliasEntry
+	var errs []error
+	for line := 1; scn.Scan(); line++ {
+		text := strings.TrimSpace(scn.Text())
+		if text == "" {
+			continue
+		}
+		target, name, ok := strings.Cut(text, ",")
+		if !ok || target == "" || name == "" {
+			errs = append(errs, fmt.Errorf("line %d: expected <USN or address>,<name>, got %q", line, text))
+			continue
+		}
+		e := AliasEntry{Name: name, line: line}
+		if group, ok := strings.CutPrefix(target, legacyGroupPrefix); ok {
+			e.Group = group
+		} else if roku.IsAddress(target) {
+			e.Address = target
+		} else {
+			e.USN = target
+		}
+		aliases = append(aliases, e)
+	}
+	if err := scn.Err(); err != nil {
+		return nil, err
+	}
+	return aliases, errors.Join(errs...)
+}
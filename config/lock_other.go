@@ -0,0 +1,11 @@
+//go:build !unix
+
+package config
+
+import "context"
+
+// lock is a no-op where flock is not available. Writes are still atomic, but
+// concurrent updates may lose one another's changes.
+func lock(ctx context.Context, lockPath string) (func(), error) {
+	return func() {}, nil
+}
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Remote configures the remote command.
+type Remote struct {
+	// Keys binds terminal keys, such as "h", "ctrl+r" or "f5", to ECP keys
+	// like "Home" or "InstantReplay", or to the remote's own "text" and
+	// "quit" actions. They change the default bindings; an empty value
+	// unbinds a key.
+	Keys map[string]string `yaml:"keys,omitempty"`
+}
+
+func (r Remote) validate() error {
+	names := make([]string, 0, len(r.Keys))
+	for name := range r.Keys {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	var errs []error
+	for _, name := range names {
+		switch key := r.Keys[name]; {
+		case name == "":
+			errs = append(errs, errors.New("remote: a key binding has no terminal key"))
+		case strings.ContainsAny(key, "/?# \t"):
+			errs = append(errs, fmt.Errorf("remote: '%s' is bound to an invalid ECP key %q", name, key))
+		}
+	}
+	return errors.Join(errs...)
+}
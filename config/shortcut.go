@@ -0,0 +1,97 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Shortcut pins a channel name to an app, given either by ID or by its exact
+// name, optionally with deep link parameters such as contentId. Shortcuts at
+// the top of the config apply to every device; those under an alias apply to
+// that device only and win over global ones with the same name.
+type Shortcut struct {
+	Name    string            `yaml:"name"`
+	App     string            `yaml:"app,omitempty"`
+	AppName string            `yaml:"app_name,omitempty"`
+	Params  map[string]string `yaml:"params,omitempty"`
+
+	line int
+}
+
+func (s *Shortcut) UnmarshalYAML(n *yaml.Node) error {
+	type plain Shortcut
+	if err := n.Decode((*plain)(s)); err != nil {
+		return err
+	}
+	s.line = n.Line
+	return nil
+}
+
+func (s Shortcut) validate() error {
+	switch {
+	case strings.TrimSpace(s.Name) == "":
+		return errors.New("shortcut has no name")
+	case (s.App == "") == (s.AppName == ""):
+		return fmt.Errorf("shortcut '%s' needs exactly one of app or app_name", s.Name)
+	}
+	return nil
+}
+
+func validateShortcuts(shortcuts []Shortcut) error {
+	var errs []error
+	seen := map[string]struct{}{}
+	for i, s := range shortcuts {
+		err := s.validate()
+		if err == nil {
+			if _, ok := seen[strings.ToLower(s.Name)]; ok {
+				err = fmt.Errorf("shortcut '%s' is defined twice", s.Name)
+			}
+			seen[strings.ToLower(s.Name)] = struct{}{}
+		}
+		if err != nil {
+			if s.line > 0 {
+				err = fmt.Errorf("line %d: %w", s.line, err)
+			}
+			errs = append(errs, fmt.Errorf("shortcuts[%d]: %w", i, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ShortcutsFor returns the shortcuts that apply to the device with the given
+// alias, sorted by name. Pass "" for the global ones only.
+func (c *Config) ShortcutsFor(alias string) []Shortcut {
+	byName := map[string]Shortcut{}
+	for _, s := range c.Shortcuts {
+		byName[strings.ToLower(s.Name)] = s
+	}
+	for _, e := range c.Aliases {
+		if alias == "" || e.Name != alias {
+			continue
+		}
+		for _, s := range e.Shortcuts {
+			byName[strings.ToLower(s.Name)] = s
+		}
+	}
+	shortcuts := make([]Shortcut, 0, len(byName))
+	for _, s := range byName {
+		shortcuts = append(shortcuts, s)
+	}
+	slices.SortFunc(shortcuts, func(a, b Shortcut) int { return strings.Compare(a.Name, b.Name) })
+	return shortcuts
+}
+
+// Shortcut returns the shortcut called name (ignoring case) for the device
+// with the given alias.
+func (c *Config) Shortcut(alias, name string) (Shortcut, bool) {
+	for _, s := range c.ShortcutsFor(alias) {
+		if strings.EqualFold(s.Name, name) {
+			return s, true
+		}
+	}
+	return Shortcut{}, false
+}
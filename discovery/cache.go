@@ -0,0 +1,76 @@
+package discovery
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/dangermike/roku_toy/cache"
+	"github.com/dangermike/roku_toy/logging"
+	"github.com/dangermike/roku_toy/roku"
+	"go.uber.org/zap"
+)
+
+// Cached reports the devices in the discovery cache whose announcements have
+// not expired and that still answer at their cached location.
+func Cached(timeout time.Duration) Discoverer {
+	return Func(func(ctx context.Context, cb func(*roku.Device) error) error {
+		log := logging.FromContext(ctx)
+		entries, err := cache.Load(ctx)
+		if err != nil {
+			return err
+		}
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		now := time.Now()
+		found := make(chan *roku.Device)
+		var wg sync.WaitGroup
+		for _, e := range entries {
+			if !e.Fresh(now) {
+				continue
+			}
+			wg.Add(1)
+			go func(e cache.Entry) {
+				defer wg.Done()
+				dev, err := cache.Verify(ctx, e, timeout)
+				if err != nil {
+					log.Debug("cached device did not verify", zap.String("USN", e.USN), zap.Error(err))
+					return
+				}
+				select {
+				case found <- dev:
+				case <-ctx.Done():
+				}
+			}(e)
+		}
+		go func() {
+			wg.Wait()
+			close(found)
+		}()
+		return drain(found, cb, cancel)
+	})
+}
+
+// Record saves every device d finds to the discovery cache once d is done.
+// Failing to save is logged and otherwise ignored.
+func Record(d Discoverer) Discoverer {
+	return Func(func(ctx context.Context, cb func(*roku.Device) error) error {
+		var seen []*roku.Device
+		err := d.Discover(ctx, func(dev *roku.Device) error {
+			seen = append(seen, dev)
+			return cb(dev)
+		})
+		if len(seen) > 0 {
+			entries, _ := cache.Load(ctx)
+			now := time.Now()
+			for _, dev := range seen {
+				entries = cache.Update(entries, dev, now)
+			}
+			if err := cache.Save(ctx, entries); err != nil {
+				logging.FromContext(ctx).Debug("failed to save device cache", zap.Error(err))
+			}
+		}
+		return err
+	})
+}
@@ -0,0 +1,264 @@
+// Package discovery finds Roku devices through interchangeable backends that
+// can be chained, merged or raced.
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dangermike/roku_toy/logging"
+	"github.com/dangermike/roku_toy/roku"
+	"go.uber.org/zap"
+)
+
+// Discoverer finds devices and reports each one to cb as it is found. cb is
+// never called concurrently. If cb returns an error, Discover stops and
+// returns an error wrapping it. Implementations should return promptly once
+// ctx is done.
+type Discoverer interface {
+	Discover(ctx context.Context, cb func(*roku.Device) error) error
+}
+
+// Func adapts a function, such as roku.SSDP, to a Discoverer.
+type Func func(ctx context.Context, cb func(*roku.Device) error) error
+
+func (f Func) Discover(ctx context.Context, cb func(*roku.Device) error) error {
+	return f(ctx, cb)
+}
+
+// SSDP searches with an M-SEARCH on the local interfaces.
+func SSDP(opts roku.SSDPOptions) Discoverer {
+	return Func(func(ctx context.Context, cb func(*roku.Device) error) error {
+		return roku.SearchSSDP(ctx, opts, cb)
+	})
+}
+
+// Scan probes the ECP port on every host of the local subnets or the given
+// CIDRs.
+func Scan(opts roku.ScanOptions) Discoverer {
+	return Func(func(ctx context.Context, cb func(*roku.Device) error) error {
+		return roku.Scan(ctx, opts, cb)
+	})
+}
+
+// Listen waits for NOTIFY announcements for the given window and reports
+// every device that announces itself. Devices only announce every few
+// minutes, so this is mostly useful merged with other backends in long
+// running commands.
+func Listen(opts roku.SSDPOptions, window time.Duration) Discoverer {
+	return Func(func(ctx context.Context, cb func(*roku.Device) error) error {
+		ctx, cancel := context.WithTimeout(ctx, window)
+		defer cancel()
+		err := roku.Watch(ctx, roku.WatchOptions{SSDPOptions: opts}, func(ev roku.Event) error {
+			if ev.Type == roku.EventLeave {
+				return nil
+			}
+			return cb(ev.Device)
+		})
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() != nil {
+			return nil
+		}
+		return err
+	})
+}
+
+// Static contacts each address directly. Addresses that do not answer are
+// skipped.
+func Static(addrs []string, timeout time.Duration) Discoverer {
+	return Func(func(ctx context.Context, cb func(*roku.Device) error) error {
+		log := logging.FromContext(ctx)
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		found := make(chan *roku.Device)
+		var wg sync.WaitGroup
+		for _, a := range addrs {
+			wg.Add(1)
+			go func(a string) {
+				defer wg.Done()
+				pctx, cancel := context.WithTimeout(ctx, timeout)
+				defer cancel()
+				dev, err := roku.FromAddress(pctx, a)
+				if err != nil {
+					log.Debug("static address did not answer", zap.String("address", a), zap.Error(err))
+					return
+				}
+				select {
+				case found <- dev:
+				case <-ctx.Done():
+				}
+			}(a)
+		}
+		go func() {
+			wg.Wait()
+			close(found)
+		}()
+		return drain(found, cb, cancel)
+	})
+}
+
+// Filter only passes on devices for which keep returns true. Fallback and
+// Race count only the devices that get through, wherever the filter is
+// applied: filtering a Fallback, Merge or Race filters each of the
+// discoverers it combines.
+func Filter(d Discoverer, keep func(*roku.Device) bool) Discoverer {
+	if c, ok := d.(combined); ok {
+		ds := make([]Discoverer, len(c.ds))
+		for i, d := range c.ds {
+			ds[i] = Filter(d, keep)
+		}
+		return c.combine(ds...)
+	}
+	return Func(func(ctx context.Context, cb func(*roku.Device) error) error {
+		return d.Discover(ctx, func(dev *roku.Device) error {
+			if !keep(dev) {
+				return nil
+			}
+			return cb(dev)
+		})
+	})
+}
+
+// combined is a discoverer made from others by Fallback, Merge or Race, which
+// remembers them so Filter can reach each one.
+type combined struct {
+	Discoverer
+	ds      []Discoverer
+	combine func(...Discoverer) Discoverer
+}
+
+// Fallback runs each discoverer in turn and stops after the first one that
+// finds anything.
+func Fallback(ds ...Discoverer) Discoverer {
+	return combined{Discoverer: fallback(ds), ds: ds, combine: Fallback}
+}
+
+func fallback(ds []Discoverer) Discoverer {
+	return Func(func(ctx context.Context, cb func(*roku.Device) error) error {
+		log := logging.FromContext(ctx)
+		seen := map[string]struct{}{}
+		var errs []error
+		for i, d := range ds {
+			var found int
+			var cbErr error
+			err := d.Discover(ctx, func(dev *roku.Device) error {
+				if _, ok := seen[dev.USN]; ok {
+					return nil
+				}
+				seen[dev.USN] = struct{}{}
+				found++
+				cbErr = cb(dev)
+				return cbErr
+			})
+			if cbErr != nil {
+				return err
+			}
+			if err != nil {
+				if ctx.Err() != nil {
+					return err
+				}
+				log.Debug("discovery backend failed", zap.Int("stage", i), zap.Error(err))
+				errs = append(errs, err)
+			}
+			if found > 0 {
+				return nil
+			}
+		}
+		if len(errs) == len(ds) && len(ds) > 0 {
+			return errors.Join(errs...)
+		}
+		return nil
+	})
+}
+
+// Merge runs every discoverer at once and reports the union of what they
+// find.
+func Merge(ds ...Discoverer) Discoverer {
+	return combined{Discoverer: concurrent(ds, false), ds: ds, combine: Merge}
+}
+
+// Race runs every discoverer at once and stops them all as soon as one
+// finishes having found something. Devices the others found in the meantime
+// are still reported.
+func Race(ds ...Discoverer) Discoverer {
+	return combined{Discoverer: concurrent(ds, true), ds: ds, combine: Race}
+}
+
+func concurrent(ds []Discoverer, race bool) Discoverer {
+	return Func(func(parent context.Context, cb func(*roku.Device) error) error {
+		log := logging.FromContext(parent)
+		ctx, cancel := context.WithCancel(parent)
+		defer cancel()
+
+		found := make(chan *roku.Device)
+		errs := make(chan error, len(ds))
+		var wg sync.WaitGroup
+		for i, d := range ds {
+			wg.Add(1)
+			go func(i int, d Discoverer) {
+				defer wg.Done()
+				var cnt int
+				err := d.Discover(ctx, func(dev *roku.Device) error {
+					cnt++
+					select {
+					case found <- dev:
+						return nil
+					case <-ctx.Done():
+						return ctx.Err()
+					}
+				})
+				if err != nil && ctx.Err() == nil {
+					log.Debug("discovery backend failed", zap.Int("backend", i), zap.Error(err))
+					errs <- err
+					return
+				}
+				if race && cnt > 0 {
+					cancel()
+				}
+			}(i, d)
+		}
+		go func() {
+			wg.Wait()
+			close(found)
+			close(errs)
+		}()
+
+		if err := drain(found, cb, cancel); err != nil {
+			return err
+		}
+		if err := parent.Err(); err != nil {
+			return err
+		}
+		var failed []error
+		for err := range errs {
+			failed = append(failed, err)
+		}
+		if len(failed) == len(ds) && len(ds) > 0 {
+			return errors.Join(failed...)
+		}
+		return nil
+	})
+}
+
+// drain reports each device from found to cb once per USN. Once cb fails the
+// senders are cancelled and the rest of found is discarded.
+func drain(found <-chan *roku.Device, cb func(*roku.Device) error, cancel context.CancelFunc) error {
+	seen := map[string]struct{}{}
+	var cbErr error
+	for dev := range found {
+		if cbErr != nil {
+			continue
+		}
+		if _, ok := seen[dev.USN]; ok {
+			continue
+		}
+		seen[dev.USN] = struct{}{}
+		if err := cb(dev); err != nil {
+			cbErr = fmt.Errorf("discovery callback returned error: %w", err)
+			cancel()
+		}
+	}
+	return cbErr
+}
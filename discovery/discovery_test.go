@@ -0,0 +1,161 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/dangermike/roku_toy/logging"
+	"github.com/dangermike/roku_toy/roku"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fixed reports the given USNs, optionally after a delay.
+func fixed(delay time.Duration, usns ...string) Discoverer {
+	return Func(func(ctx context.Context, cb func(*roku.Device) error) error {
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		for _, u := range usns {
+			if err := cb(&roku.Device{USN: u}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func failing(msg string) Discoverer {
+	return Func(func(context.Context, func(*roku.Device) error) error {
+		return errors.New(msg)
+	})
+}
+
+func collect(t *testing.T, d Discoverer) ([]string, error) {
+	t.Helper()
+	ctx := logging.NewContext(context.Background(), zap.NewNop())
+	var usns []string
+	err := d.Discover(ctx, func(dev *roku.Device) error {
+		usns = append(usns, dev.USN)
+		return nil
+	})
+	sort.Strings(usns)
+	return usns, err
+}
+
+func TestFallback(t *testing.T) {
+	usns, err := collect(t, Fallback(fixed(0), failing("boom"), fixed(0, "A", "B"), fixed(0, "C")))
+	require.NoError(t, err)
+	require.Equal(t, []string{"A", "B"}, usns)
+
+	_, err = collect(t, Fallback(failing("one"), failing("two")))
+	require.ErrorContains(t, err, "one")
+	require.ErrorContains(t, err, "two")
+
+	usns, err = collect(t, Fallback(fixed(0), fixed(0)))
+	require.NoError(t, err)
+	require.Empty(t, usns)
+}
+
+func TestMerge(t *testing.T) {
+	usns, err := collect(t, Merge(fixed(0, "A", "B"), fixed(10*time.Millisecond, "B", "C"), failing("boom")))
+	require.NoError(t, err)
+	require.Equal(t, []string{"A", "B", "C"}, usns)
+
+	_, err = collect(t, Merge(failing("one"), failing("two")))
+	require.Error(t, err)
+}
+
+func TestRace(t *testing.T) {
+	usns, err := collect(t, Race(fixed(0, "A"), fixed(time.Second, "B")))
+	require.NoError(t, err)
+	require.Equal(t, []string{"A"}, usns)
+
+	// a backend that finds nothing does not win the race
+	usns, err = collect(t, Race(fixed(0), fixed(10*time.Millisecond, "B")))
+	require.NoError(t, err)
+	require.Equal(t, []string{"B"}, usns)
+}
+
+func TestCallbackErrorStops(t *testing.T) {
+	stop := errors.New("stop")
+	ctx := logging.NewContext(context.Background(), zap.NewNop())
+	for name, d := range map[string]Discoverer{
+		"fallback": Fallback(fixed(0), fixed(0, "A", "B"), fixed(0, "C")),
+		"merge":    Merge(fixed(0, "A"), fixed(time.Minute, "B")),
+		"race":     Race(fixed(0, "A"), fixed(time.Minute, "B")),
+	} {
+		t.Run(name, func(t *testing.T) {
+			var calls int
+			start := time.Now()
+			err := d.Discover(ctx, func(*roku.Device) error {
+				calls++
+				return stop
+			})
+			require.ErrorIs(t, err, stop)
+			require.Equal(t, 1, calls)
+			require.Less(t, time.Since(start), 10*time.Second)
+		})
+	}
+}
+
+func TestFilter(t *testing.T) {
+	usns, err := collect(t, Fallback(
+		Filter(fixed(0, "A", "B"), func(dev *roku.Device) bool { return dev.USN == "C" }),
+		fixed(0, "C"),
+	))
+	require.NoError(t, err)
+	require.Equal(t, []string{"C"}, usns)
+
+	// filtering the whole chain must not let a stage whose devices are all
+	// filtered out end it, e.g. a cached device when another was asked for
+	onlyB := func(dev *roku.Device) bool { return dev.USN == "B" }
+	usns, err = collect(t, Filter(Fallback(fixed(0, "A"), fixed(0, "B")), onlyB))
+	require.NoError(t, err)
+	require.Equal(t, []string{"B"}, usns)
+
+	usns, err = collect(t, Filter(Fallback(Race(fixed(0, "A"), fixed(10*time.Millisecond, "C")), Merge(fixed(0, "B"))), onlyB))
+	require.NoError(t, err)
+	require.Equal(t, []string{"B"}, usns)
+}
+
+func TestParse(t *testing.T) {
+	backends := map[string]Discoverer{
+		"a":    fixed(0, "A"),
+		"b":    fixed(0, "B"),
+		"none": fixed(0),
+	}
+	for _, test := range []struct {
+		spec string
+		exp  []string
+	}{
+		{"a", []string{"A"}},
+		{"none,a,b", []string{"A"}},
+		{"none, a+b", []string{"A", "B"}},
+		{"none|a", []string{"A"}},
+	} {
+		t.Run(test.spec, func(t *testing.T) {
+			d, err := Parse(test.spec, backends)
+			require.NoError(t, err)
+			usns, err := collect(t, d)
+			require.NoError(t, err)
+			require.Equal(t, test.exp, usns)
+		})
+	}
+
+	for _, bad := range []string{"", "c", "a+b|none", " , "} {
+		_, err := Parse(bad, backends)
+		require.Error(t, err, bad)
+	}
+}
+
+func TestWithout(t *testing.T) {
+	require.Equal(t, "ssdp+static,scan", Without("cache,ssdp+static,scan", "cache"))
+	require.Equal(t, "ssdp|static,scan", Without("ssdp|cache|static,scan", "cache"))
+	require.Equal(t, "ssdp", Without("ssdp", "cache"))
+}
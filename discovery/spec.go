@@ -0,0 +1,79 @@
+package discovery
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Parse builds a discoverer from a chain description such as
+// "cache,ssdp+static,scan". Stages separated by commas are tried in order
+// until one finds something (Fallback). Within a stage, backends joined with
+// "+" run together and their results are merged (Merge), while backends
+// joined with "|" stop as soon as one of them finds something (Race). The
+// names refer to entries in backends.
+func Parse(spec string, backends map[string]Discoverer) (Discoverer, error) {
+	var stages []Discoverer
+	for _, stage := range strings.Split(spec, ",") {
+		stage = strings.TrimSpace(stage)
+		if stage == "" {
+			continue
+		}
+		sep, combine := "+", Merge
+		if strings.Contains(stage, "|") {
+			if strings.Contains(stage, "+") {
+				return nil, fmt.Errorf("discovery stage '%s' mixes '+' and '|'", stage)
+			}
+			sep, combine = "|", Race
+		}
+		var ds []Discoverer
+		for _, name := range strings.Split(stage, sep) {
+			name = strings.TrimSpace(name)
+			d, ok := backends[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown discovery backend '%s' (known: %s)", name, strings.Join(names(backends), ", "))
+			}
+			ds = append(ds, d)
+		}
+		if len(ds) == 1 {
+			stages = append(stages, ds[0])
+		} else {
+			stages = append(stages, combine(ds...))
+		}
+	}
+	switch len(stages) {
+	case 0:
+		return nil, fmt.Errorf("discovery chain '%s' is empty", spec)
+	case 1:
+		return stages[0], nil
+	}
+	return Fallback(stages...), nil
+}
+
+// Without removes a backend from a chain description, dropping stages that
+// end up empty.
+func Without(spec, name string) string {
+	var stages []string
+	for _, stage := range strings.Split(spec, ",") {
+		parts := strings.FieldsFunc(stage, func(r rune) bool { return r == '+' || r == '|' })
+		sep := "+"
+		if strings.Contains(stage, "|") {
+			sep = "|"
+		}
+		parts = slices.DeleteFunc(parts, func(p string) bool { return strings.TrimSpace(p) == name })
+		if len(parts) > 0 {
+			stages = append(stages, strings.Join(parts, sep))
+		}
+	}
+	return strings.Join(stages, ",")
+}
+
+func names(backends map[string]Discoverer) []string {
+	var n []string
+	for k := range backends {
+		n = append(n, k)
+	}
+	sort.Strings(n)
+	return n
+}
@@ -0,0 +1,132 @@
+// Package emulator serves the Roku ECP API and answers SSDP searches from
+// in-memory state so roku_toy can be used without a real device.
+package emulator
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dangermike/roku_toy/roku"
+	"gopkg.in/yaml.v3"
+)
+
+// BasePort is the HTTP port of the first virtual device. Devices without a
+// port get consecutive ports from here.
+const BasePort = 8060
+
+type Config struct {
+	// Interface is the network interface SSDP is answered on. Empty uses the
+	// system default for multicast.
+	Interface string `yaml:"interface"`
+	// NoSSDP disables the SSDP responder; devices are only reachable by
+	// address.
+	NoSSDP  bool           `yaml:"no_ssdp"`
+	Devices []DeviceConfig `yaml:"devices"`
+}
+
+type DeviceConfig struct {
+	USN   string `yaml:"usn"`
+	Group string `yaml:"group"`
+	Name  string `yaml:"name"`
+	Model string `yaml:"model"`
+	// Port is the ECP HTTP port. Defaults to BasePort plus the device's
+	// index.
+	Port int `yaml:"port"`
+	// PowerMode is reported in device-info, e.g. PowerOn or DisplayOff.
+	PowerMode string     `yaml:"power_mode"`
+	Apps      []roku.App `yaml:"apps"`
+	// ActiveApp is the ID of the app running at start. Empty is the home
+	// screen.
+	ActiveApp string `yaml:"active_app"`
+	Media     Media  `yaml:"media"`
+	// AppUI is the raw XML served from /query/app-ui.
+	AppUI string `yaml:"app_ui"`
+}
+
+// Media is the state reported by /query/media-player.
+type Media struct {
+	// State is one of close, play, pause, stop or buffer.
+	State    string        `yaml:"state"`
+	Position time.Duration `yaml:"position"`
+	Duration time.Duration `yaml:"duration"`
+}
+
+// DefaultApps is the app list of a device that was not given one.
+func DefaultApps() []roku.App {
+	return []roku.App{
+		{ID: "2285", Version: "6.81.0", Name: "Hulu"},
+		{ID: "12", Version: "4.2.100079005", Name: "Netflix"},
+		{ID: "837", Version: "2.20.110005159", Name: "YouTube"},
+		{ID: "551012", Version: "14.2.89", Name: "Apple TV"},
+		{ID: "151908", Version: "9.3.10", Name: "The Roku Channel"},
+		{ID: "23353", Version: "5.6.1", Name: "PBS"},
+		{ID: "13", Version: "15.1.2024030812", Name: "Prime Video"},
+		{ID: "22297", Version: "2.11.67", Name: "Spotify Music"},
+	}
+}
+
+// DefaultConfig is a single device with the default apps.
+func DefaultConfig() Config {
+	return Config{Devices: []DeviceConfig{{}}}
+}
+
+// LoadConfig reads a YAML config file.
+func LoadConfig(path string) (Config, error) {
+	var cfg Config
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read emulator config: %w", err)
+	}
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("failed to parse emulator config '%s': %w", path, err)
+	}
+	if len(cfg.Devices) == 0 {
+		return cfg, fmt.Errorf("emulator config '%s' has no devices", path)
+	}
+	return cfg, nil
+}
+
+// withDefaults fills in everything a device needs to look real. i is the
+// device's index in the config.
+func (c DeviceConfig) withDefaults(i int) DeviceConfig {
+	if c.USN == "" {
+		c.USN = fmt.Sprintf("EMU%09d", i+1)
+	}
+	if c.Name == "" {
+		c.Name = fmt.Sprintf("Emulated Roku %d", i+1)
+	}
+	if c.Model == "" {
+		c.Model = "3941X"
+	}
+	if c.Port == 0 {
+		c.Port = BasePort + i
+	}
+	if c.PowerMode == "" {
+		c.PowerMode = "PowerOn"
+	}
+	if c.Apps == nil {
+		c.Apps = DefaultApps()
+	}
+	if c.Media.State == "" {
+		c.Media.State = "close"
+	}
+	return c
+}
+
+func (c Config) validate() error {
+	usns := map[string]struct{}{}
+	ports := map[int]struct{}{}
+	for i, d := range c.Devices {
+		d = d.withDefaults(i)
+		if _, ok := usns[d.USN]; ok {
+			return fmt.Errorf("device %d: duplicate USN '%s'", i+1, d.USN)
+		}
+		usns[d.USN] = struct{}{}
+		if _, ok := ports[d.Port]; ok {
+			return fmt.Errorf("device %d: duplicate port %d", i+1, d.Port)
+		}
+		ports[d.Port] = struct{}{}
+	}
+	return nil
+}
@@ -0,0 +1,275 @@
+package emulator
+
+import (
+	"encoding/xml"
+	"fmt"
+	"hash/crc32"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dangermike/roku_toy/roku"
+	"go.uber.org/zap"
+)
+
+// Keypress is one entry of a device's keypress log.
+type Keypress struct {
+	Time time.Time
+	Key  string
+	// Action is press, down or up.
+	Action string
+}
+
+// Device is a virtual Roku. All of its state is in memory and safe for
+// concurrent use.
+type Device struct {
+	cfg DeviceConfig
+	log *zap.Logger
+
+	mu         sync.Mutex
+	active     string
+	power      string
+	media      Media
+	keypresses []Keypress
+}
+
+// NewDevice creates a device from cfg. Missing settings get the same defaults
+// as the first device of a config file.
+func NewDevice(cfg DeviceConfig, log *zap.Logger) *Device {
+	cfg = cfg.withDefaults(0)
+	if log == nil {
+		log = zap.NewNop()
+	}
+	return &Device{
+		cfg:    cfg,
+		log:    log.With(zap.String("USN", cfg.USN)),
+		active: cfg.ActiveApp,
+		power:  cfg.PowerMode,
+		media:  cfg.Media,
+	}
+}
+
+func (d *Device) USN() string   { return d.cfg.USN }
+func (d *Device) Group() string { return d.cfg.Group }
+func (d *Device) Port() int     { return d.cfg.Port }
+
+// ActiveApp returns the ID of the running app, or "" on the home screen.
+func (d *Device) ActiveApp() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.active
+}
+
+func (d *Device) PowerMode() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.power
+}
+
+func (d *Device) Media() Media {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.media
+}
+
+// Keypresses returns a copy of the keypress log.
+func (d *Device) Keypresses() []Keypress {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]Keypress(nil), d.keypresses...)
+}
+
+// Info is what /query/device-info reports.
+func (d *Device) Info() roku.DeviceInfo {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return roku.DeviceInfo{
+		UDN:                fmt.Sprintf("29600009-0000-1000-8000-%012x", crc32.ChecksumIEEE([]byte(d.cfg.USN))),
+		SerialNumber:       d.cfg.USN,
+		DeviceID:           d.cfg.USN,
+		VendorName:         "Roku",
+		ModelName:          "Roku Emulator",
+		ModelNumber:        d.cfg.Model,
+		FriendlyDeviceName: d.cfg.Name,
+		UserDeviceName:     d.cfg.Name,
+		DefaultDeviceName:  "Roku Emulator - " + d.cfg.USN,
+		SoftwareVersion:    "12.5.0",
+		SoftwareBuild:      "4178",
+		NetworkType:        "ethernet",
+		PowerMode:          d.power,
+	}
+}
+
+func (d *Device) findApp(id string) (roku.App, bool) {
+	for _, a := range d.cfg.Apps {
+		if a.ID == id {
+			return a, true
+		}
+	}
+	return roku.App{}, false
+}
+
+// Handler serves the ECP endpoints that roku_toy uses.
+func (d *Device) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /query/apps", d.handleApps)
+	mux.HandleFunc("GET /query/active-app", d.handleActiveApp)
+	mux.HandleFunc("GET /query/device-info", d.handleDeviceInfo)
+	mux.HandleFunc("GET /query/media-player", d.handleMediaPlayer)
+	mux.HandleFunc("GET /query/app-ui", d.handleAppUI)
+	mux.HandleFunc("POST /launch/{id}", d.handleLaunch)
+	mux.HandleFunc("POST /keypress/{key}", d.handleKey("press"))
+	mux.HandleFunc("POST /keydown/{key}", d.handleKey("down"))
+	mux.HandleFunc("POST /keyup/{key}", d.handleKey("up"))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d.log.Info("request", zap.String("method", r.Method), zap.String("path", r.URL.Path))
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// xmlApp is an app as the device writes it. roku.App keeps the raw inner XML
+// of the name, so it can't be used to write one.
+type xmlApp struct {
+	ID      string `xml:"id,attr,omitempty"`
+	Type    string `xml:"type,attr,omitempty"`
+	Version string `xml:"version,attr,omitempty"`
+	Name    string `xml:",chardata"`
+}
+
+func toXMLApp(a roku.App) xmlApp {
+	return xmlApp{ID: a.ID, Type: "appl", Version: a.Version, Name: a.Name}
+}
+
+func writeXML(w http.ResponseWriter, v any) {
+	data, err := xml.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/xml; charset=\"utf-8\"")
+	_, _ = w.Write([]byte(xml.Header))
+	_, _ = w.Write(data)
+}
+
+func (d *Device) handleApps(w http.ResponseWriter, r *http.Request) {
+	out := struct {
+		XMLName xml.Name `xml:"apps"`
+		Apps    []xmlApp `xml:"app"`
+	}{}
+	for _, a := range d.cfg.Apps {
+		out.Apps = append(out.Apps, toXMLApp(a))
+	}
+	writeXML(w, out)
+}
+
+func (d *Device) handleActiveApp(w http.ResponseWriter, r *http.Request) {
+	app := xmlApp{Name: "Roku"}
+	if a, ok := d.findApp(d.ActiveApp()); ok {
+		app = toXMLApp(a)
+	}
+	writeXML(w, struct {
+		XMLName xml.Name `xml:"active-app"`
+		App     xmlApp   `xml:"app"`
+	}{App: app})
+}
+
+func (d *Device) handleDeviceInfo(w http.ResponseWriter, r *http.Request) {
+	writeXML(w, struct {
+		XMLName xml.Name `xml:"device-info"`
+		roku.DeviceInfo
+	}{DeviceInfo: d.Info()})
+}
+
+func (d *Device) handleMediaPlayer(w http.ResponseWriter, r *http.Request) {
+	type plugin struct {
+		ID   string `xml:"id,attr"`
+		Name string `xml:"name,attr"`
+	}
+	out := struct {
+		XMLName  xml.Name `xml:"player"`
+		Error    bool     `xml:"error,attr"`
+		State    string   `xml:"state,attr"`
+		Plugin   *plugin  `xml:"plugin,omitempty"`
+		Position string   `xml:"position,omitempty"`
+		Duration string   `xml:"duration,omitempty"`
+		IsLive   *bool    `xml:"is_live,omitempty"`
+	}{}
+	m := d.Media()
+	out.State = m.State
+	if a, ok := d.findApp(d.ActiveApp()); ok && m.State != "close" {
+		live := false
+		out.Plugin = &plugin{ID: a.ID, Name: a.Name}
+		out.Position = fmt.Sprintf("%d ms", m.Position.Milliseconds())
+		out.Duration = fmt.Sprintf("%d ms", m.Duration.Milliseconds())
+		out.IsLive = &live
+	}
+	writeXML(w, out)
+}
+
+func (d *Device) handleAppUI(w http.ResponseWriter, r *http.Request) {
+	doc := d.cfg.AppUI
+	if doc == "" {
+		doc = `<app-ui><topscreen><screen focused="true" type="HomeScreen"/></topscreen></app-ui>`
+	}
+	w.Header().Set("Content-Type", "text/xml; charset=\"utf-8\"")
+	_, _ = w.Write([]byte(doc))
+}
+
+func (d *Device) handleLaunch(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, ok := d.findApp(id); !ok {
+		http.NotFound(w, r)
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.power = "PowerOn"
+	if d.active == id {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	d.active = id
+	d.media = Media{State: "close"}
+	d.log.Info("launched app", zap.String("id", id))
+}
+
+func (d *Device) handleKey(action string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.PathValue("key")
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		d.keypresses = append(d.keypresses, Keypress{Time: time.Now(), Key: key, Action: action})
+		d.log.Info("key", zap.String("key", key), zap.String("action", action))
+		if action != "up" {
+			d.applyKey(key)
+		}
+	}
+}
+
+// applyKey changes the state the way the key would on a real device. Keys
+// without a visible effect are only logged. Must be called with mu held.
+func (d *Device) applyKey(key string) {
+	switch strings.ToLower(key) {
+	case "home":
+		d.active = ""
+		d.media = Media{State: "close"}
+	case "poweron":
+		d.power = "PowerOn"
+	case "poweroff":
+		d.power = "DisplayOff"
+	case "power":
+		if d.power == "PowerOn" {
+			d.power = "DisplayOff"
+		} else {
+			d.power = "PowerOn"
+		}
+	case "play":
+		switch d.media.State {
+		case "play":
+			d.media.State = "pause"
+		case "pause", "stop":
+			d.media.State = "play"
+		}
+	}
+}
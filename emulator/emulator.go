@@ -0,0 +1,102 @@
+package emulator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Emulator is a set of virtual devices and their servers.
+type Emulator struct {
+	cfg     Config
+	log     *zap.Logger
+	Devices []*Device
+}
+
+func New(cfg Config, log *zap.Logger) (*Emulator, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	if log == nil {
+		log = zap.NewNop()
+	}
+	e := &Emulator{cfg: cfg, log: log}
+	for i, dc := range cfg.Devices {
+		e.Devices = append(e.Devices, NewDevice(dc.withDefaults(i), log))
+	}
+	return e, nil
+}
+
+// Run serves ECP for every device, and answers SSDP unless disabled, until
+// ctx is done.
+func (e *Emulator) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	lns := make([]net.Listener, len(e.Devices))
+	for i, d := range e.Devices {
+		ln, err := net.Listen("tcp", ":"+strconv.Itoa(d.Port()))
+		if err != nil {
+			for _, l := range lns[:i] {
+				l.Close()
+			}
+			return fmt.Errorf("failed to listen for device %s: %w", d.USN(), err)
+		}
+		lns[i] = ln
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(e.Devices)+1)
+	for i, d := range e.Devices {
+		ln := lns[i]
+		srv := &http.Server{Handler: d.Handler(), ReadHeaderTimeout: 10 * time.Second}
+		e.log.Info("serving ECP", zap.String("USN", d.USN()), zap.Stringer("address", ln.Addr()))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := srv.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+				errs <- fmt.Errorf("ECP server for %s failed: %w", d.USN(), err)
+				cancel()
+			}
+		}()
+		stop := context.AfterFunc(ctx, func() {
+			sctx, scancel := context.WithTimeout(context.Background(), time.Second)
+			defer scancel()
+			_ = srv.Shutdown(sctx)
+		})
+		defer stop()
+	}
+
+	if !e.cfg.NoSSDP {
+		r, err := newResponder(e.cfg.Interface, e.Devices, e.log)
+		if err != nil {
+			cancel()
+			wg.Wait()
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := r.serve(ctx); err != nil {
+				errs <- err
+				cancel()
+			}
+		}()
+	}
+
+	<-ctx.Done()
+	wg.Wait()
+	close(errs)
+	var failed []error
+	for err := range errs {
+		failed = append(failed, err)
+	}
+	return errors.Join(failed...)
+}
@@ -0,0 +1,128 @@
+package emulator
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dangermike/roku_toy/logging"
+	"github.com/dangermike/roku_toy/roku"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func testDevice(t *testing.T, cfg DeviceConfig) (*Device, *roku.Device, context.Context) {
+	t.Helper()
+	d := NewDevice(cfg, nil)
+	srv := httptest.NewServer(d.Handler())
+	t.Cleanup(srv.Close)
+	loc, err := url.Parse(srv.URL + "/")
+	require.NoError(t, err)
+	return d, &roku.Device{Location: loc, USN: d.USN()}, logging.NewContext(context.Background(), zap.NewNop())
+}
+
+func TestDeviceECP(t *testing.T) {
+	d, rd, ctx := testDevice(t, DeviceConfig{USN: "X1", Name: "Den"})
+
+	apps, err := rd.QueryApps(ctx)
+	require.NoError(t, err)
+	require.Equal(t, len(DefaultApps()), len(apps))
+
+	app, err := rd.ActiveApp(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "Roku", app.Name)
+
+	require.NoError(t, rd.LaunchByName(ctx, "netflix"))
+	require.Equal(t, "12", d.ActiveApp())
+	require.NoError(t, rd.Launch(ctx, "12"), "launching the running app is a 204")
+	app, err = rd.ActiveApp(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "12", app.ID)
+	require.Error(t, rd.Launch(ctx, "99999"))
+
+	require.NoError(t, rd.Keypress(ctx, "PowerOff"))
+	info, err := rd.QueryDeviceInfo(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "X1", info.SerialNumber)
+	require.Equal(t, "Den", info.UserDeviceName)
+	require.Equal(t, "DisplayOff", info.PowerMode)
+
+	require.NoError(t, rd.Home(ctx))
+	require.Equal(t, "", d.ActiveApp())
+
+	var keys []string
+	for _, k := range d.Keypresses() {
+		keys = append(keys, k.Key)
+	}
+	require.Equal(t, []string{"PowerOff", "home"}, keys)
+}
+
+func TestMediaPlayer(t *testing.T) {
+	d, rd, _ := testDevice(t, DeviceConfig{ActiveApp: "837", Media: Media{State: "play", Position: time.Minute, Duration: time.Hour}})
+
+	resp, err := http.Get(rd.Location.JoinPath("query", "media-player").String())
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var buf bytes.Buffer
+	_, err = buf.ReadFrom(resp.Body)
+	require.NoError(t, err)
+	require.Contains(t, buf.String(), `<player error="false" state="play"><plugin id="837" name="YouTube"></plugin><position>60000 ms</position>`)
+
+	req, err := http.NewRequest(http.MethodPost, rd.Location.JoinPath("keypress", "Play").String(), nil)
+	require.NoError(t, err)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, "pause", d.Media().State)
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "emu.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+devices:
+  - usn: LIVING
+    group: house
+    name: Living Room
+    active_app: "2"
+    apps:
+      - {id: "1", name: Alpha, version: "1.0"}
+      - {id: "2", name: Beta}
+    media: {state: pause, position: 90s, duration: 45m}
+  - usn: BEDROOM
+`), 0o644))
+	cfg, err := LoadConfig(path)
+	require.NoError(t, err)
+	require.Len(t, cfg.Devices, 2)
+	require.Equal(t, 90*time.Second, cfg.Devices[0].Media.Position)
+
+	emu, err := New(cfg, nil)
+	require.NoError(t, err)
+	require.Equal(t, 8060, emu.Devices[0].Port())
+	require.Equal(t, 8061, emu.Devices[1].Port())
+	require.Equal(t, "2", emu.Devices[0].ActiveApp())
+	require.Equal(t, "PowerOn", emu.Devices[1].PowerMode())
+
+	cfg.Devices[1].USN = "LIVING"
+	_, err = New(cfg, nil)
+	require.Error(t, err)
+}
+
+func TestSearchResponse(t *testing.T) {
+	require.True(t, isSearch([]byte("M-SEARCH * HTTP/1.1\r\nHost: 239.255.255.250:1900\r\nMan: \"ssdp:discover\"\r\nST: roku:ecp\r\nMX: 1\r\n\r\n")))
+	require.False(t, isSearch([]byte("M-SEARCH * HTTP/1.1\r\nST: urn:dial-multiscreen-org:service:dial:1\r\n\r\n")))
+
+	d := NewDevice(DeviceConfig{USN: "X1", Group: "G", Port: 9000}, nil)
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(searchResponse(d, net.IPv4(10, 0, 0, 5)))), nil)
+	require.NoError(t, err)
+	require.Equal(t, "http://10.0.0.5:9000/", resp.Header.Get("Location"))
+	require.Equal(t, "uuid:roku:ecp:X1", resp.Header.Get("USN"))
+	require.Equal(t, "G", resp.Header.Get("device-group.roku.com"))
+}
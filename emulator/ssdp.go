@@ -0,0 +1,178 @@
+package emulator
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// maxAge is the Cache-Control max-age advertised for every device.
+	maxAge = 30 * time.Minute
+	// announceInterval is how often ssdp:alive is repeated, well within
+	// maxAge so watchers don't expire the devices.
+	announceInterval = 5 * time.Minute
+)
+
+var ssdpGroup = &net.UDPAddr{IP: net.IPv4(239, 255, 255, 250), Port: 1900}
+
+// responder answers M-SEARCH requests and sends NOTIFY announcements for a
+// set of devices.
+type responder struct {
+	conn    *net.UDPConn
+	devices []*Device
+	log     *zap.Logger
+}
+
+func newResponder(iface string, devices []*Device, log *zap.Logger) (*responder, error) {
+	var ifi *net.Interface
+	if iface != "" {
+		var err error
+		if ifi, err = net.InterfaceByName(iface); err != nil {
+			return nil, fmt.Errorf("failed to find interface '%s': %w", iface, err)
+		}
+	}
+	conn, err := net.ListenMulticastUDP("udp4", ifi, ssdpGroup)
+	if err != nil {
+		return nil, fmt.Errorf("failed to join the SSDP multicast group: %w", err)
+	}
+	return &responder{conn: conn, devices: devices, log: log}, nil
+}
+
+// serve answers searches until ctx is done, then says goodbye.
+func (r *responder) serve(ctx context.Context) error {
+	stop := context.AfterFunc(ctx, func() { r.conn.Close() })
+	defer stop()
+	defer r.conn.Close()
+
+	go r.announce(ctx)
+
+	buf := make([]byte, 1<<16)
+	for {
+		cnt, src, err := r.conn.ReadFromUDP(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				r.notify("ssdp:byebye")
+				return nil
+			}
+			return fmt.Errorf("failed to read SSDP request: %w", err)
+		}
+		if !isSearch(buf[:cnt]) {
+			continue
+		}
+		host, err := localAddrFor(src)
+		if err != nil {
+			r.log.Debug("no route back to searcher", zap.Stringer("source", src), zap.Error(err))
+			continue
+		}
+		for _, d := range r.devices {
+			if _, err := r.conn.WriteToUDP(searchResponse(d, host), src); err != nil {
+				r.log.Debug("failed to answer search", zap.Stringer("source", src), zap.Error(err))
+			}
+		}
+		r.log.Info("answered search", zap.Stringer("source", src), zap.Int("devices", len(r.devices)))
+	}
+}
+
+func (r *responder) announce(ctx context.Context) {
+	tick := time.NewTicker(announceInterval)
+	defer tick.Stop()
+	for {
+		r.notify("ssdp:alive")
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+// notify multicasts an announcement for every device. A byebye is sent from a
+// fresh socket since the listening one is closed by then.
+func (r *responder) notify(nts string) {
+	host, err := localAddrFor(ssdpGroup)
+	if err != nil {
+		r.log.Debug("failed to pick an announcement address", zap.Error(err))
+		return
+	}
+	conn, err := net.DialUDP("udp4", nil, ssdpGroup)
+	if err != nil {
+		r.log.Debug("failed to announce", zap.String("NTS", nts), zap.Error(err))
+		return
+	}
+	defer conn.Close()
+	for _, d := range r.devices {
+		if _, err := conn.Write(notifyRequest(d, host, nts)); err != nil {
+			r.log.Debug("failed to announce", zap.String("NTS", nts), zap.Error(err))
+		}
+	}
+}
+
+// isSearch reports whether data is an M-SEARCH a Roku would answer.
+func isSearch(data []byte) bool {
+	req, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(data)))
+	if err != nil || req.Method != "M-SEARCH" {
+		return false
+	}
+	st := req.Header.Get("ST")
+	return st == "roku:ecp" || st == "ssdp:all"
+}
+
+// localAddrFor returns the local address the kernel would use to reach to.
+func localAddrFor(to *net.UDPAddr) (net.IP, error) {
+	conn, err := net.DialUDP("udp4", nil, to)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP, nil
+}
+
+func location(d *Device, host net.IP) string {
+	return "http://" + net.JoinHostPort(host.String(), strconv.Itoa(d.Port())) + "/"
+}
+
+func ssdpMessage(first string, headers [][2]string) []byte {
+	var sb strings.Builder
+	sb.WriteString(first)
+	sb.WriteString("\r\n")
+	for _, h := range headers {
+		if h[1] == "" {
+			continue
+		}
+		sb.WriteString(h[0] + ": " + h[1] + "\r\n")
+	}
+	sb.WriteString("\r\n")
+	return []byte(sb.String())
+}
+
+func searchResponse(d *Device, host net.IP) []byte {
+	return ssdpMessage("HTTP/1.1 200 OK", [][2]string{
+		{"Cache-Control", fmt.Sprintf("max-age=%d", int(maxAge.Seconds()))},
+		{"ST", "roku:ecp"},
+		{"Location", location(d, host)},
+		{"USN", "uuid:roku:ecp:" + d.USN()},
+		{"Server", "Roku/12.5.0 UPnP/1.0 Roku/12.5.0"},
+		{"device-group.roku.com", d.Group()},
+	})
+}
+
+func notifyRequest(d *Device, host net.IP, nts string) []byte {
+	return ssdpMessage("NOTIFY * HTTP/1.1", [][2]string{
+		{"Host", ssdpGroup.String()},
+		{"Cache-Control", fmt.Sprintf("max-age=%d", int(maxAge.Seconds()))},
+		{"NT", "roku:ecp"},
+		{"NTS", nts},
+		{"Location", location(d, host)},
+		{"USN", "uuid:roku:ecp:" + d.USN()},
+		{"device-group.roku.com", d.Group()},
+	})
+}
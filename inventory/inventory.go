@@ -0,0 +1,148 @@
+// Package inventory remembers the aliased devices roku_toy has seen, so they
+// can still be listed and identified while they are offline. Unlike the
+// discovery cache it is never pruned on its own; devices stay until they are
+// forgotten.
+package inventory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dangermike/roku_toy/logging"
+	"github.com/dangermike/roku_toy/roku"
+	"github.com/dangermike/roku_toy/xdg"
+	"go.uber.org/zap"
+)
+
+// Device is what was last known about a device.
+type Device struct {
+	USN             string    `json:"usn"`
+	Location        string    `json:"location"`
+	DeviceGroup     string    `json:"device_group,omitempty"`
+	Name            string    `json:"name,omitempty"`
+	Model           string    `json:"model,omitempty"`
+	ModelNumber     string    `json:"model_number,omitempty"`
+	Serial          string    `json:"serial,omitempty"`
+	WifiMAC         string    `json:"wifi_mac,omitempty"`
+	EthernetMAC     string    `json:"ethernet_mac,omitempty"`
+	NetworkType     string    `json:"network_type,omitempty"`
+	SoftwareVersion string    `json:"software_version,omitempty"`
+	LastSeen        time.Time `json:"last_seen"`
+}
+
+// MAC is the address of the interface the device was connected with.
+func (d Device) MAC() string {
+	if d.NetworkType == "ethernet" || d.WifiMAC == "" {
+		return d.EthernetMAC
+	}
+	return d.WifiMAC
+}
+
+func filePath() (string, error) {
+	dir, err := xdg.StateHome()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "inventory.json"), nil
+}
+
+// Load returns the known devices, sorted by USN.
+func Load(ctx context.Context) ([]Device, error) {
+	targetPath, err := filePath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(targetPath)
+	if errors.Is(err, os.ErrNotExist) {
+		logging.FromContext(ctx).Debug("no device inventory", zap.String("path", targetPath))
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read device inventory: %w", err)
+	}
+	var devs []Device
+	if err := json.Unmarshal(data, &devs); err != nil {
+		return nil, fmt.Errorf("invalid device inventory %s: %w", targetPath, err)
+	}
+	return devs, nil
+}
+
+// Save replaces the inventory. It is written to a temporary file that is
+// renamed into place so a failed write leaves the old one intact.
+func Save(ctx context.Context, devs []Device) error {
+	targetPath, err := filePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0o755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	data, err := json.MarshalIndent(devs, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(targetPath), ".inventory.json.*")
+	if err != nil {
+		return fmt.Errorf("failed to write device inventory: %w", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write device inventory: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write device inventory: %w", err)
+	}
+	if err := os.Rename(f.Name(), targetPath); err != nil {
+		return fmt.Errorf("failed to write device inventory: %w", err)
+	}
+	logging.FromContext(ctx).Debug("saved device inventory", zap.String("path", targetPath))
+	return nil
+}
+
+// Find returns the device with usn, if known.
+func Find(devs []Device, usn string) (Device, bool) {
+	i := slices.IndexFunc(devs, func(d Device) bool { return d.USN == usn })
+	if i < 0 {
+		return Device{}, false
+	}
+	return devs[i], true
+}
+
+// Record adds or updates dev, seen at now. info may be empty if the device
+// didn't answer, in which case what was known before is kept.
+func Record(devs []Device, dev *roku.Device, info roku.DeviceInfo, now time.Time) []Device {
+	d, _ := Find(devs, dev.USN)
+	d.USN = dev.USN
+	d.Location = dev.Location.String()
+	d.DeviceGroup = dev.DeviceGroup
+	d.LastSeen = now
+	if info.SerialNumber != "" {
+		d.Name = info.UserDeviceName
+		if d.Name == "" {
+			d.Name = info.FriendlyDeviceName
+		}
+		d.Model = info.ModelName
+		d.ModelNumber = info.ModelNumber
+		d.Serial = info.SerialNumber
+		d.WifiMAC = info.WifiMAC
+		d.EthernetMAC = info.EthernetMAC
+		d.NetworkType = info.NetworkType
+		d.SoftwareVersion = strings.TrimSpace(info.SoftwareVersion + " " + info.SoftwareBuild)
+	}
+	devs = Forget(devs, dev.USN)
+	devs = append(devs, d)
+	slices.SortFunc(devs, func(a, b Device) int { return strings.Compare(a.USN, b.USN) })
+	return devs
+}
+
+// Forget removes the device with usn.
+func Forget(devs []Device, usn string) []Device {
+	return slices.DeleteFunc(devs, func(d Device) bool { return d.USN == usn })
+}
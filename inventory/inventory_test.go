@@ -0,0 +1,59 @@
+package inventory
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dangermike/roku_toy/logging"
+	"github.com/dangermike/roku_toy/roku"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestRecord(t *testing.T) {
+	loc, _ := url.Parse("http://192.168.1.5:8060/")
+	dev := &roku.Device{USN: "B", Location: loc}
+	first := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	devs := Record([]Device{{USN: "C"}}, dev, roku.DeviceInfo{
+		SerialNumber:       "B",
+		FriendlyDeviceName: "Den",
+		ModelName:          "Roku Ultra",
+		WifiMAC:            "aa:bb",
+		EthernetMAC:        "cc:dd",
+		NetworkType:        "ethernet",
+	}, first)
+	require.Len(t, devs, 2)
+	require.Equal(t, "B", devs[0].USN, "sorted by USN")
+	require.Equal(t, "Den", devs[0].Name)
+	require.Equal(t, "cc:dd", devs[0].MAC())
+
+	loc, _ = url.Parse("http://192.168.1.6:8060/")
+	dev.Location = loc
+	later := first.Add(time.Hour)
+	devs = Record(devs, dev, roku.DeviceInfo{}, later)
+	require.Len(t, devs, 2)
+	require.Equal(t, "http://192.168.1.6:8060/", devs[0].Location)
+	require.Equal(t, later, devs[0].LastSeen)
+	require.Equal(t, "Roku Ultra", devs[0].Model, "kept when device-info is missing")
+
+	c := devs[1]
+	require.Equal(t, []Device{c}, Forget(devs, "B"))
+}
+
+func TestSaveLoad(t *testing.T) {
+	ctx := logging.NewContext(context.Background(), zap.NewNop())
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	devs, err := Load(ctx)
+	require.NoError(t, err)
+	require.Empty(t, devs)
+
+	want := []Device{{USN: "A", Location: "http://10.0.0.1:8060/", Model: "Roku Express", LastSeen: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)}}
+	require.NoError(t, Save(ctx, want))
+	devs, err = Load(ctx)
+	require.NoError(t, err)
+	require.Equal(t, want, devs)
+}
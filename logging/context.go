@@ -31,7 +31,7 @@ func NewContext(ctx context.Context, l *zap.Logger) context.Context {
 }
 
 func FromContext(ctx context.Context) *zap.Logger {
-	logger := ctx.Value(key).(*zap.Logger)
+	logger, _ := ctx.Value(key).(*zap.Logger)
 	if logger == nil {
 		return zap.L()
 	}
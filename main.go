@@ -1,15 +1,28 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
 
 	"github.com/dangermike/roku_toy/cmd"
 )
 
 func main() {
-	if err := cmd.Cmd().Execute(); err != nil {
+	// Ctrl-C cancels the context so discovery and requests stop right away
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	err := cmd.Cmd().ExecuteContext(ctx)
+	stop()
+	if err != nil {
 		fmt.Fprintln(os.Stderr, err.Error())
+		// commands run against several devices report partial failure
+		// with their own exit code
+		var coded interface{ ExitCode() int }
+		if errors.As(err, &coded) {
+			os.Exit(coded.ExitCode())
+		}
 		os.Exit(1)
 	}
 }
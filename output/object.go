@@ -0,0 +1,185 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Field is a named value of an Object, kept as JSON.
+type Field struct {
+	Name  string
+	Value json.RawMessage
+}
+
+// Object is a record with its fields in a fixed order. Records are given to
+// a Printer as structs whose json tags are the stable field names; they are
+// turned into Objects so every format sees the same names in the same order.
+type Object []Field
+
+// ToObject converts v, which must marshal to a JSON object, into an Object.
+func ToObject(v any) (Object, error) {
+	if o, ok := v.(Object); ok {
+		return o, nil
+	}
+	data, err := marshalJSON(v, "")
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, fmt.Errorf("%T is not a record", v)
+	}
+	var o Object
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, err
+		}
+		o = append(o, Field{Name: tok.(string), Value: raw})
+	}
+	return o, nil
+}
+
+// Merge joins the fields of several records into one. A field that appears
+// more than once keeps its first value.
+func Merge(vs ...any) (Object, error) {
+	var merged Object
+	for _, v := range vs {
+		if v == nil {
+			continue
+		}
+		o, err := ToObject(v)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range o {
+			if merged.index(f.Name) < 0 {
+				merged = append(merged, f)
+			}
+		}
+	}
+	return merged, nil
+}
+
+func (o Object) index(name string) int {
+	for i, f := range o {
+		if f.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (o Object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := marshalJSON(f.Name, "")
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(f.Value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// MarshalYAML keeps the field order, which a map would lose.
+func (o Object) MarshalYAML() (any, error) {
+	n := &yaml.Node{Kind: yaml.MappingNode}
+	for _, f := range o {
+		var v yaml.Node
+		if err := yaml.Unmarshal(f.Value, &v); err != nil {
+			return nil, err
+		}
+		val := v.Content[0]
+		blockStyle(val)
+		n.Content = append(n.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: f.Name}, val)
+	}
+	return n, nil
+}
+
+// blockStyle undoes the flow style and quoting YAML gives values parsed from
+// JSON. Strings that need quotes, like "12", still get them.
+func blockStyle(n *yaml.Node) {
+	n.Style = 0
+	for _, c := range n.Content {
+		blockStyle(c)
+	}
+}
+
+// Map returns the fields as a map for templates.
+func (o Object) Map() (map[string]any, error) {
+	m := make(map[string]any, len(o))
+	for _, f := range o {
+		var v any
+		if err := json.Unmarshal(f.Value, &v); err != nil {
+			return nil, err
+		}
+		m[f.Name] = v
+	}
+	return m, nil
+}
+
+// Cell is the field as text for tables and CSV: strings as they are, lists
+// joined with commas and nested records as JSON. Missing and null fields are
+// empty.
+func (o Object) Cell(name string) string {
+	i := o.index(name)
+	if i < 0 {
+		return ""
+	}
+	return cell(o[i].Value)
+}
+
+func cell(raw json.RawMessage) string {
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return string(raw)
+	}
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []any:
+		parts := make([]string, len(v))
+		for i, e := range v {
+			b, err := marshalJSON(e, "")
+			if err != nil {
+				return string(raw)
+			}
+			parts[i] = cell(b)
+		}
+		return strings.Join(parts, ",")
+	case map[string]any:
+		return string(raw)
+	}
+	return strings.TrimSpace(string(raw))
+}
+
+// marshalJSON is json.Marshal without escaping &, < and >, which would make
+// app names like "Plex - Free Movies & TV" hard to read.
+func marshalJSON(v any, indent string) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", indent)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
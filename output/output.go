@@ -0,0 +1,286 @@
+// Package output prints command results in the format chosen with the
+// --output flag. Results are structs (or slices of them) whose json tags are
+// the field names used by every format, so scripts can rely on them.
+package output
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Formats accepted by New. Template is given as go-template=<template>.
+const (
+	Table    = "table"
+	JSON     = "json"
+	JSONL    = "jsonl"
+	YAML     = "yaml"
+	CSV      = "csv"
+	Template = "go-template"
+)
+
+// Usage describes the --output flag.
+const Usage = "output format: table, json, jsonl, yaml, csv or go-template=<template>"
+
+// Printer writes results to w in one format.
+type Printer struct {
+	w      io.Writer
+	format string
+	tmpl   *template.Template
+
+	// streamed is set once Stream has written the header of a table or CSV.
+	streamed bool
+	// headed is set once Heading has written a heading.
+	headed bool
+}
+
+// New returns a Printer for the format named by spec, e.g. "json" or
+// "go-template={{.name}}". An empty spec means table.
+func New(w io.Writer, spec string) (*Printer, error) {
+	p := &Printer{w: w, format: spec}
+	if spec == "" {
+		p.format = Table
+	}
+	if text, ok := strings.CutPrefix(spec, Template+"="); ok {
+		tmpl, err := template.New("output").Option("missingkey=zero").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid output template: %w", err)
+		}
+		p.format, p.tmpl = Template, tmpl
+	}
+	switch p.format {
+	case Table, JSON, JSONL, YAML, CSV:
+	case Template:
+		if p.tmpl == nil {
+			return nil, fmt.Errorf("output %s needs a template, as in %s={{.name}}", Template, Template)
+		}
+	default:
+		return nil, fmt.Errorf("unknown output format '%s' (want table, json, jsonl, yaml, csv or go-template=...)", spec)
+	}
+	return p, nil
+}
+
+// Format is the name of the format, without the template.
+func (p *Printer) Format() string {
+	return p.format
+}
+
+// Structured reports whether the format is meant for programs rather than
+// people.
+func (p *Printer) Structured() bool {
+	return p.format != Table
+}
+
+// Heading starts a titled section of a table, separated from the previous
+// section by a blank line. Other formats have no headings, so nothing is
+// written for them.
+func (p *Printer) Heading(title string) error {
+	if p.Structured() {
+		return nil
+	}
+	sep := ""
+	if p.headed {
+		sep = "\n"
+	}
+	p.headed = true
+	_, err := fmt.Fprintf(p.w, "%s%s:\n", sep, title)
+	return err
+}
+
+// Print writes a complete result: a record, a slice of records, or nil for
+// nothing. JSON and YAML print a list as an array even if it has one element,
+// and a single record as an object.
+func (p *Printer) Print(v any) error {
+	objs, list, err := objects(v)
+	if err != nil || objs == nil && !list {
+		return err
+	}
+	switch p.format {
+	case JSON:
+		var data []byte
+		if list {
+			data, err = marshalJSON(objs, "  ")
+		} else {
+			data, err = marshalJSON(objs[0], "  ")
+		}
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(p.w, "%s\n", data)
+		return err
+	case YAML:
+		enc := yaml.NewEncoder(p.w)
+		enc.SetIndent(2)
+		if list {
+			err = enc.Encode(objs)
+		} else {
+			err = enc.Encode(objs[0])
+		}
+		if err != nil {
+			return err
+		}
+		return enc.Close()
+	case CSV:
+		return p.csv(objs, true)
+	case Table:
+		return p.table(objs)
+	}
+	for _, o := range objs {
+		if err := p.Stream(o); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stream writes one record of a series that is printed as it happens, such
+// as events. Tables can't be aligned ahead of time, so their values are
+// separated by single spaces without a header. JSON is written one record
+// per line, and YAML as one document per record.
+func (p *Printer) Stream(v any) error {
+	o, err := ToObject(v)
+	if err != nil {
+		return err
+	}
+	switch p.format {
+	case JSON, JSONL:
+		data, err := marshalJSON(o, "")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(p.w, "%s\n", data)
+		return err
+	case YAML:
+		data, err := yaml.Marshal(o)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(p.w, "---\n%s", data)
+		return err
+	case CSV:
+		header := !p.streamed
+		p.streamed = true
+		return p.csv([]Object{o}, header)
+	case Template:
+		m, err := o.Map()
+		if err != nil {
+			return err
+		}
+		var sb strings.Builder
+		if err := p.tmpl.Execute(&sb, m); err != nil {
+			return err
+		}
+		out := sb.String()
+		if !strings.HasSuffix(out, "\n") {
+			out += "\n"
+		}
+		_, err = io.WriteString(p.w, out)
+		return err
+	}
+	var vals []string
+	for _, f := range o {
+		if c := cell(f.Value); c != "" {
+			vals = append(vals, c)
+		}
+	}
+	_, err = fmt.Fprintln(p.w, strings.Join(vals, " "))
+	return err
+}
+
+func (p *Printer) table(objs []Object) error {
+	if len(objs) == 0 {
+		return nil
+	}
+	cols := columns(objs)
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	header := make([]string, len(cols))
+	for i, c := range cols {
+		header[i] = strings.ToUpper(strings.ReplaceAll(c, "_", " "))
+	}
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, o := range objs {
+		row := make([]string, len(cols))
+		for i, c := range cols {
+			row[i] = o.Cell(c)
+		}
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+func (p *Printer) csv(objs []Object, header bool) error {
+	cols := columns(objs)
+	w := csv.NewWriter(p.w)
+	if header {
+		if err := w.Write(cols); err != nil {
+			return err
+		}
+	}
+	for _, o := range objs {
+		row := make([]string, len(cols))
+		for i, c := range cols {
+			row[i] = o.Cell(c)
+		}
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// columns are the field names of objs. A name missing from the first
+// records is placed after the field it follows where it does appear, so
+// optional fields keep their place.
+func columns(objs []Object) []string {
+	var cols []string
+	for _, o := range objs {
+		prev := -1
+		for _, f := range o {
+			i := slices.Index(cols, f.Name)
+			if i < 0 {
+				i = prev + 1
+				cols = slices.Insert(cols, i, f.Name)
+			}
+			prev = i
+		}
+	}
+	return cols
+}
+
+// objects converts v to records, reporting whether it was a list.
+func objects(v any) ([]Object, bool, error) {
+	if v == nil {
+		return nil, false, nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer && rv.IsNil() {
+		return nil, false, nil
+	}
+	if rv.Kind() != reflect.Slice {
+		o, err := ToObject(v)
+		if err != nil {
+			return nil, false, err
+		}
+		return []Object{o}, false, nil
+	}
+	if o, ok := v.(Object); ok {
+		return []Object{o}, false, nil
+	}
+	objs := make([]Object, rv.Len())
+	for i := range objs {
+		o, err := ToObject(rv.Index(i).Interface())
+		if err != nil {
+			return nil, false, err
+		}
+		objs[i] = o
+	}
+	return objs, true, nil
+}
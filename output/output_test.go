@@ -0,0 +1,142 @@
+package output
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type app struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Version string   `json:"version"`
+	Tags    []string `json:"tags,omitempty"`
+}
+
+var apps = []app{
+	{ID: "12", Name: "Netflix", Version: "4.2"},
+	{ID: "13535", Name: "Plex - Free Movies & TV", Tags: []string{"a", "b"}},
+}
+
+func print(t *testing.T, spec string, v any) string {
+	t.Helper()
+	var sb strings.Builder
+	p, err := New(&sb, spec)
+	require.NoError(t, err)
+	require.NoError(t, p.Print(v))
+	return sb.String()
+}
+
+func TestPrint(t *testing.T) {
+	require.Equal(t, ""+
+		"ID     NAME                     VERSION  TAGS\n"+
+		"12     Netflix                  4.2      \n"+
+		"13535  Plex - Free Movies & TV           a,b\n", print(t, "", apps))
+
+	require.Equal(t, `[
+  {
+    "id": "12",
+    "name": "Netflix",
+    "version": "4.2"
+  },
+  {
+    "id": "13535",
+    "name": "Plex - Free Movies & TV",
+    "version": "",
+    "tags": [
+      "a",
+      "b"
+    ]
+  }
+]
+`, print(t, "json", apps))
+	require.Equal(t, "{\n  \"id\": \"12\",\n  \"name\": \"Netflix\",\n  \"version\": \"4.2\"\n}\n", print(t, "json", apps[0]))
+	require.Equal(t, "[]\n", print(t, "json", []app(nil)))
+
+	require.Equal(t, ""+
+		"{\"id\":\"12\",\"name\":\"Netflix\",\"version\":\"4.2\"}\n"+
+		"{\"id\":\"13535\",\"name\":\"Plex - Free Movies & TV\",\"version\":\"\",\"tags\":[\"a\",\"b\"]}\n", print(t, "jsonl", apps))
+
+	require.Equal(t, `- id: "12"
+  name: Netflix
+  version: "4.2"
+- id: "13535"
+  name: Plex - Free Movies & TV
+  version: ""
+  tags:
+    - a
+    - b
+`, print(t, "yaml", apps))
+
+	require.Equal(t, "id,name,version,tags\n12,Netflix,4.2,\n13535,Plex - Free Movies & TV,,\"a,b\"\n", print(t, "csv", apps))
+
+	require.Equal(t, "12=Netflix\n13535=Plex - Free Movies & TV\n", print(t, "go-template={{.id}}={{.name}}", apps))
+
+	require.Empty(t, print(t, "table", nil))
+}
+
+func TestHeading(t *testing.T) {
+	var sb strings.Builder
+	p, err := New(&sb, "")
+	require.NoError(t, err)
+	require.NoError(t, p.Heading("den"))
+	require.NoError(t, p.Print(apps[:1]))
+	require.NoError(t, p.Heading("kitchen"))
+	require.NoError(t, p.Print(apps[1:]))
+	require.Equal(t, ""+
+		"den:\n"+
+		"ID  NAME     VERSION\n"+
+		"12  Netflix  4.2\n"+
+		"\n"+
+		"kitchen:\n"+
+		"ID     NAME                     VERSION  TAGS\n"+
+		"13535  Plex - Free Movies & TV           a,b\n", sb.String())
+
+	sb.Reset()
+	p, err = New(&sb, "jsonl")
+	require.NoError(t, err)
+	require.NoError(t, p.Heading("den"))
+	require.Empty(t, sb.String())
+}
+
+func TestNewErrors(t *testing.T) {
+	_, err := New(nil, "xml")
+	require.ErrorContains(t, err, "unknown output format 'xml'")
+	_, err = New(nil, "go-template")
+	require.ErrorContains(t, err, "needs a template")
+	_, err = New(nil, "go-template={{.id")
+	require.ErrorContains(t, err, "invalid output template")
+}
+
+func TestMerge(t *testing.T) {
+	o, err := Merge(struct {
+		Device string `json:"device"`
+		OK     bool   `json:"ok"`
+	}{"den", true}, nil, apps[0])
+	require.NoError(t, err)
+	require.Equal(t, "device ok id name version", strings.Join(columns([]Object{o}), " "))
+	require.Equal(t, "true", o.Cell("ok"))
+
+	var sb strings.Builder
+	p, err := New(&sb, "table")
+	require.NoError(t, err)
+	require.NoError(t, p.Stream(o))
+	require.Equal(t, "den true 12 Netflix 4.2\n", sb.String())
+}
+
+func TestColumns(t *testing.T) {
+	a, _ := ToObject(map[string]string{"usn": "A"})
+	b, _ := Merge(struct {
+		USN   string `json:"usn"`
+		Alias string `json:"alias"`
+		Model string `json:"model"`
+	}{}, struct {
+		Online bool `json:"online"`
+	}{})
+	c, _ := Merge(struct {
+		USN    string `json:"usn"`
+		Online bool   `json:"online"`
+	}{})
+	require.Equal(t, []string{"usn", "alias", "model", "online"}, columns([]Object{a, c, b}))
+}
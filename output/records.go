@@ -0,0 +1,103 @@
+package output
+
+import (
+	"html"
+	"time"
+
+	"github.com/dangermike/roku_toy/roku"
+)
+
+// The records below are what commands print. Their json tags are the field
+// names in every format and only ever gain fields.
+
+// App is an installed or active app.
+type App struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// NewApp converts an app as the device reported it, with XML escapes in the
+// name, to a record.
+func NewApp(a roku.App) App {
+	return App{ID: a.ID, Name: html.UnescapeString(a.Name), Version: a.Version}
+}
+
+// Device is a device on the network or one seen before.
+type Device struct {
+	USN      string   `json:"usn"`
+	Location string   `json:"location"`
+	Alias    string   `json:"alias"`
+	Group    string   `json:"group"`
+	Room     string   `json:"room"`
+	Tags     []string `json:"tags"`
+	Model    string   `json:"model"`
+	// Serial, MAC and SoftwareVersion tell devices apart, and are kept from
+	// when the device was last seen if it is offline.
+	Serial          string     `json:"serial"`
+	MAC             string     `json:"mac"`
+	SoftwareVersion string     `json:"software_version"`
+	Online          bool       `json:"online"`
+	LastSeen        *time.Time `json:"last_seen"`
+}
+
+// Event is a device arriving, leaving or moving.
+type Event struct {
+	Time     time.Time `json:"time"`
+	Type     string    `json:"type"`
+	USN      string    `json:"usn"`
+	Location string    `json:"location"`
+	Previous string    `json:"previous"`
+	Alias    string    `json:"alias"`
+	Expired  bool      `json:"expired"`
+}
+
+// Node is an element of the on-screen UI.
+type Node struct {
+	Path  string            `json:"path"`
+	Tag   string            `json:"tag"`
+	Attrs map[string]string `json:"attrs"`
+}
+
+// NewNode converts a UI node to a record.
+func NewNode(n *roku.UINode) Node {
+	attrs := make(map[string]string, len(n.Attrs))
+	for _, a := range n.Attrs {
+		attrs[a.Name.Local] = a.Value
+	}
+	return Node{Path: n.Path(), Tag: n.Tag(), Attrs: attrs}
+}
+
+// Power is a device's power state.
+type Power struct {
+	PowerMode string `json:"power_mode"`
+}
+
+// Favorite is a channel shortcut and the app it launches on a device.
+type Favorite struct {
+	Name      string            `json:"name"`
+	AppID     string            `json:"app_id"`
+	AppName   string            `json:"app_name"`
+	Params    map[string]string `json:"params"`
+	Installed bool              `json:"installed"`
+}
+
+// Forgotten is a device removed from the known-device inventory. Its alias is
+// removed too unless it was kept.
+type Forgotten struct {
+	USN       string `json:"usn"`
+	Alias     string `json:"alias"`
+	Forgotten bool   `json:"forgotten"`
+}
+
+// Snapshot is the outcome of comparing a screenshot to its golden image, or
+// of replacing the golden with it. DiffPath is set when a diff image was
+// written.
+type Snapshot struct {
+	Name        string `json:"name"`
+	OK          bool   `json:"ok"`
+	Updated     bool   `json:"updated"`
+	DiffPixels  int    `json:"diff_pixels"`
+	TotalPixels int    `json:"total_pixels"`
+	DiffPath    string `json:"diff_path"`
+}
@@ -0,0 +1,96 @@
+package remote
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Actions of the remote itself, which terminal keys can be bound to
+// instead of ECP keys.
+const (
+	// ActionText starts typing text into the device. Esc stops.
+	ActionText = "text"
+	// ActionQuit leaves the remote. ctrl+c always does.
+	ActionQuit = "quit"
+)
+
+// DefaultKeys binds terminal keys to the ECP keys they press, or to an
+// action.
+var DefaultKeys = map[string]string{
+	"up":        "Up",
+	"down":      "Down",
+	"left":      "Left",
+	"right":     "Right",
+	"enter":     "Select",
+	"backspace": "Back",
+	"esc":       "Back",
+	"space":     "Play",
+	"[":         "Rev",
+	"]":         "Fwd",
+	"h":         "Home",
+	"home":      "Home",
+	"*":         "Info",
+	"i":         "Info",
+	"r":         "InstantReplay",
+	"s":         "Search",
+	"+":         "VolumeUp",
+	"=":         "VolumeUp",
+	"-":         "VolumeDown",
+	"m":         "VolumeMute",
+	"pgup":      "ChannelUp",
+	"pgdn":      "ChannelDown",
+	"p":         "Power",
+	"t":         ActionText,
+	"q":         ActionQuit,
+}
+
+// Bindings returns DefaultKeys changed by overrides, which map terminal
+// keys to ECP keys or actions as in the config file. An empty value
+// removes the default binding of a key.
+func Bindings(overrides map[string]string) (map[string]string, error) {
+	keys := maps.Clone(DefaultKeys)
+	var errs []error
+	for _, name := range sortedKeys(overrides) {
+		key := normalize(name)
+		if !ValidKey(key) {
+			errs = append(errs, fmt.Errorf("unknown terminal key '%s'", name))
+			continue
+		}
+		if overrides[name] == "" {
+			delete(keys, key)
+		} else {
+			keys[key] = overrides[name]
+		}
+	}
+	// there has to be a way out
+	keys["ctrl+c"] = ActionQuit
+	return keys, errors.Join(errs...)
+}
+
+// help lists what each bound ECP key or action is pressed with, sorted by
+// the ECP key, e.g. "Back: backspace, esc".
+func help(keys map[string]string) []string {
+	byTarget := map[string][]string{}
+	for key, target := range keys {
+		byTarget[target] = append(byTarget[target], key)
+	}
+	var lines []string
+	for _, target := range sortedKeys(byTarget) {
+		names := byTarget[target]
+		slices.Sort(names)
+		lines = append(lines, fmt.Sprintf("%s: %s", target, strings.Join(names, ", ")))
+	}
+	return lines
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,126 @@
+// Package remote is a full-screen remote control for the terminal. It reads
+// keys from a terminal in raw mode, presses the ECP keys they are bound to
+// and shows what the device is doing.
+package remote
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Terminal keys are named by the character they type, such as "a", "P" or
+// "[", or by one of these names. Modifiers other than ctrl on letters are
+// ignored, so shift+up reads as up.
+var keyNames = []string{
+	"up", "down", "left", "right", "enter", "backspace", "tab", "esc", "space",
+	"insert", "delete", "home", "end", "pgup", "pgdn",
+}
+
+// csiKeys are the escape sequences ending in a letter, e.g. ESC [ A.
+var csiKeys = map[byte]string{
+	'A': "up", 'B': "down", 'C': "right", 'D': "left", 'H': "home", 'F': "end",
+	'P': "f1", 'Q': "f2", 'R': "f3", 'S': "f4",
+}
+
+// tildeKeys are the escape sequences ending in '~', e.g. ESC [ 3 ~, by
+// number.
+var tildeKeys = map[int]string{
+	1: "home", 2: "insert", 3: "delete", 4: "end", 5: "pgup", 6: "pgdn", 7: "home", 8: "end",
+	11: "f1", 12: "f2", 13: "f3", 14: "f4", 15: "f5", 17: "f6", 18: "f7", 19: "f8",
+	20: "f9", 21: "f10", 23: "f11", 24: "f12",
+}
+
+// ValidKey reports whether name is a terminal key Decode can return.
+func ValidKey(name string) bool {
+	if r, size := utf8.DecodeRuneInString(name); size == len(name) && r != utf8.RuneError {
+		return unicode.IsPrint(r) && r != ' '
+	}
+	for _, n := range keyNames {
+		if name == n {
+			return true
+		}
+	}
+	if letter, ok := strings.CutPrefix(name, "ctrl+"); ok {
+		// the terminal sends ctrl+h, i, j and m as backspace, tab and enter
+		return len(letter) == 1 && letter[0] >= 'a' && letter[0] <= 'z' && !strings.Contains("hijm", letter)
+	}
+	if n, ok := strings.CutPrefix(name, "f"); ok {
+		i, err := strconv.Atoi(n)
+		return err == nil && i >= 1 && i <= 12 && n == strconv.Itoa(i)
+	}
+	return false
+}
+
+// normalize spells a key name from the config the way Decode does: named
+// keys in lower case, characters as they are.
+func normalize(name string) string {
+	if utf8.RuneCountInString(name) == 1 {
+		return name
+	}
+	return strings.ToLower(name)
+}
+
+// Decode reads the first key from b, which holds what the terminal sent. It
+// returns the key's name and how many bytes it took up. Sequences it doesn't
+// know are skipped with an empty name.
+func Decode(b []byte) (string, int) {
+	if len(b) == 0 {
+		return "", 0
+	}
+	switch c := b[0]; {
+	case c == 0x1b:
+		return decodeEscape(b)
+	case c == '\r' || c == '\n':
+		return "enter", 1
+	case c == '\t':
+		return "tab", 1
+	case c == 0x7f || c == 0x08:
+		return "backspace", 1
+	case c == ' ':
+		return "space", 1
+	case c >= 1 && c <= 26:
+		return "ctrl+" + string(rune('a'+c-1)), 1
+	case c < 0x20:
+		return "", 1
+	}
+	r, size := utf8.DecodeRune(b)
+	if r == utf8.RuneError || !unicode.IsPrint(r) {
+		return "", size
+	}
+	return string(r), size
+}
+
+// decodeEscape reads a key that starts with ESC: a CSI (ESC [) or SS3
+// (ESC O) sequence, or else the escape key itself.
+func decodeEscape(b []byte) (string, int) {
+	if len(b) < 2 || (b[1] != '[' && b[1] != 'O') {
+		return "esc", 1
+	}
+	if b[1] == 'O' {
+		if len(b) < 3 {
+			return "esc", 1
+		}
+		return csiKeys[b[2]], 3
+	}
+	// parameters and intermediates run up to a final byte in 0x40-0x7e
+	end := 2
+	for end < len(b) && (b[end] < 0x40 || b[end] > 0x7e) {
+		end++
+	}
+	if end == len(b) {
+		return "", len(b)
+	}
+	params, final := string(b[2:end]), b[end]
+	if final != '~' {
+		return csiKeys[final], end + 1
+	}
+	// a modifier follows the number after ';'
+	num, _, _ := strings.Cut(params, ";")
+	n, err := strconv.Atoi(num)
+	if err != nil {
+		return "", end + 1
+	}
+	return tildeKeys[n], end + 1
+}
@@ -0,0 +1,291 @@
+package remote
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/dangermike/roku_toy/logging"
+	"github.com/dangermike/roku_toy/roku"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultRefresh is how often the status pane is updated when no keys
+	// are pressed.
+	DefaultRefresh = 2 * time.Second
+
+	// requestTimeout bounds each keypress and status request, so a device
+	// that went away doesn't freeze the remote.
+	requestTimeout = 3 * time.Second
+)
+
+// Terminal control sequences. Output goes to a terminal in raw mode, so
+// lines end in \r\n.
+const (
+	enterScreen = "\x1b[?1049h\x1b[?25l"
+	leaveScreen = "\x1b[?25h\x1b[?1049l"
+	clearScreen = "\x1b[H\x1b[2J"
+)
+
+// Remote controls one device from a terminal.
+type Remote struct {
+	Device *roku.Device
+	// Name is shown in the status pane, e.g. the device's alias.
+	Name string
+	// Keys binds terminal keys to ECP keys or actions, see Bindings.
+	Keys map[string]string
+	// Refresh is how often the status is updated. Zero means
+	// DefaultRefresh.
+	Refresh time.Duration
+}
+
+// Status is what the status pane shows.
+type Status struct {
+	Power string
+	App   string
+	Media roku.MediaPlayer
+	Err   error
+}
+
+// state is everything the screen shows.
+type state struct {
+	status Status
+	typing bool
+	// last is the last key pressed on the device, or what went wrong.
+	last string
+	err  error
+}
+
+// Run shows the remote on out and handles keys read from in, which should
+// be a terminal in raw mode, until a key bound to ActionQuit is pressed, in
+// is closed or ctx is done.
+func (r *Remote) Run(ctx context.Context, in io.Reader, out io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	log := logging.FromContext(ctx)
+
+	fmt.Fprint(out, enterScreen)
+	defer fmt.Fprint(out, leaveScreen)
+
+	keys := make(chan string)
+	readErr := make(chan error, 1)
+	go func() {
+		readErr <- readKeys(ctx, in, keys)
+	}()
+
+	statuses := make(chan Status)
+	kick := make(chan struct{}, 1)
+	go r.poll(ctx, statuses, kick)
+
+	var st state
+	for {
+		r.render(out, st)
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-readErr:
+			return err
+		case st.status = <-statuses:
+		case key := <-keys:
+			quit, err := r.handle(ctx, &st, key)
+			if quit {
+				return nil
+			}
+			st.err = err
+			if err != nil {
+				log.Debug("keypress failed", zap.String("key", key), zap.Error(err))
+			}
+			select {
+			case kick <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// readKeys sends the keys read from in on keys until in ends.
+func readKeys(ctx context.Context, in io.Reader, keys chan<- string) error {
+	buf := make([]byte, 256)
+	for {
+		n, err := in.Read(buf)
+		for b := buf[:n]; len(b) > 0; {
+			key, size := Decode(b)
+			b = b[size:]
+			if key == "" {
+				continue
+			}
+			select {
+			case keys <- key:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read keys: %w", err)
+		}
+	}
+}
+
+// handle acts on a terminal key. It reports whether the remote should quit.
+func (r *Remote) handle(ctx context.Context, st *state, key string) (bool, error) {
+	if st.typing {
+		switch key {
+		case "ctrl+c":
+			return true, nil
+		case "esc":
+			st.typing = false
+			return false, nil
+		case "enter":
+			return false, r.press(ctx, st, "Enter")
+		case "backspace":
+			return false, r.press(ctx, st, "Backspace")
+		case "space":
+			return false, r.press(ctx, st, roku.LiteralKey(' '))
+		}
+		if rs := []rune(key); len(rs) == 1 {
+			return false, r.press(ctx, st, roku.LiteralKey(rs[0]))
+		}
+	}
+	switch target := r.Keys[key]; target {
+	case "":
+		st.last = fmt.Sprintf("%s is not bound", key)
+		return false, nil
+	case ActionQuit:
+		return true, nil
+	case ActionText:
+		st.typing = true
+		return false, nil
+	default:
+		return false, r.press(ctx, st, target)
+	}
+}
+
+func (r *Remote) press(ctx context.Context, st *state, key string) error {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	st.last = key
+	return r.Device.Keypress(ctx, key)
+}
+
+// poll sends the device's status every Refresh, and right away when kicked.
+func (r *Remote) poll(ctx context.Context, statuses chan<- Status, kick <-chan struct{}) {
+	refresh := r.Refresh
+	if refresh <= 0 {
+		refresh = DefaultRefresh
+	}
+	ticker := time.NewTicker(refresh)
+	defer ticker.Stop()
+	for {
+		st := r.status(ctx)
+		select {
+		case statuses <- st:
+		case <-ctx.Done():
+			return
+		}
+		select {
+		case <-ticker.C:
+		case <-kick:
+			// give the device a moment to act on the key
+			time.Sleep(200 * time.Millisecond)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (r *Remote) status(ctx context.Context) Status {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	var st Status
+	info, err := r.Device.QueryDeviceInfo(ctx)
+	if err != nil {
+		st.Err = err
+		return st
+	}
+	st.Power = info.PowerMode
+	app, err := r.Device.ActiveApp(ctx)
+	if err != nil {
+		st.Err = err
+		return st
+	}
+	st.App = html.UnescapeString(app.Name)
+	if app.ID != "" {
+		st.App += " (" + app.ID + ")"
+	}
+	// not every device has a media player to ask
+	if st.Media, err = r.Device.QueryMediaPlayer(ctx); err != nil {
+		logging.FromContext(ctx).Debug("failed to get media player", zap.Error(err))
+	}
+	return st
+}
+
+func (r *Remote) render(out io.Writer, st state) {
+	var sb strings.Builder
+	line := func(format string, args ...any) {
+		fmt.Fprintf(&sb, format+"\r\n", args...)
+	}
+	sb.WriteString(clearScreen)
+	line("roku_toy remote: %s", r.Name)
+	line("")
+	if st.status.Err != nil {
+		line("  status:  %v", st.status.Err)
+	} else {
+		line("  power:   %s", orNone(st.status.Power))
+		line("  app:     %s", orNone(st.status.App))
+		line("  media:   %s", media(st.status.Media))
+	}
+	line("")
+	if st.typing {
+		line("  TEXT: typed characters go to the device, enter and backspace too; esc stops")
+	} else {
+		line("  NAVIGATE")
+	}
+	if st.err != nil {
+		line("  error:   %v", st.err)
+	} else {
+		line("  last:    %s", orNone(st.last))
+	}
+	line("")
+	for _, h := range help(r.Keys) {
+		line("  %s", h)
+	}
+	_, _ = io.WriteString(out, sb.String())
+}
+
+// media describes the media player state, e.g. "play 1:30 / 45:00".
+func media(mp roku.MediaPlayer) string {
+	if mp.State == "" {
+		return "-"
+	}
+	s := mp.State
+	if mp.Live {
+		return s + " (live)"
+	}
+	if mp.Duration > 0 {
+		s += fmt.Sprintf(" %s / %s", clock(mp.Position), clock(mp.Duration))
+	}
+	return s
+}
+
+// clock formats d as m:ss, or h:mm:ss from an hour on.
+func clock(d time.Duration) string {
+	s := int(d.Round(time.Second).Seconds())
+	if s >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+	}
+	return fmt.Sprintf("%d:%02d", s/60, s%60)
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,65 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dangermike/roku_toy/rokutest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecode(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		key  string
+		size int
+	}{
+		{"a", "a", 1},
+		{"ab", "a", 1},
+		{"é", "é", 2},
+		{"\r", "enter", 1},
+		{"\x7f", "backspace", 1},
+		{"\x03", "ctrl+c", 1},
+		{" ", "space", 1},
+		{"\x1b", "esc", 1},
+		{"\x1b[A", "up", 3},
+		{"\x1bOB", "down", 3},
+		{"\x1b[5~x", "pgup", 4},
+		{"\x1b[15~", "f5", 5},
+	} {
+		key, size := Decode([]byte(test.in))
+		require.Equal(t, test.key, key, "%q", test.in)
+		require.Equal(t, test.size, size, "%q", test.in)
+	}
+}
+
+func TestBindings(t *testing.T) {
+	keys, err := Bindings(map[string]string{"ctrl+r": "InstantReplay", "p": "", "F5": "Home", "ctrl+c": ""})
+	require.NoError(t, err)
+	require.Equal(t, "InstantReplay", keys["ctrl+r"])
+	require.Equal(t, "Home", keys["f5"])
+	require.NotContains(t, keys, "p")
+	require.Equal(t, ActionQuit, keys["ctrl+c"], "ctrl+c always quits")
+	require.Equal(t, "Power", DefaultKeys["p"], "defaults are left alone")
+
+	_, err = Bindings(map[string]string{"hyper+x": "Home"})
+	require.ErrorContains(t, err, "unknown terminal key 'hyper+x'")
+}
+
+func TestRun(t *testing.T) {
+	srv := rokutest.NewServer(t)
+	keys, err := Bindings(nil)
+	require.NoError(t, err)
+	r := &Remote{Device: srv.Device, Name: "den", Keys: keys, Refresh: time.Hour}
+
+	var out bytes.Buffer
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	require.NoError(t, r.Run(ctx, strings.NewReader("\x1b[A\rta/\x1bxq"), &out))
+	srv.AssertKeys(t, "Up", "Select", "Lit_a", "Lit_/")
+	require.Contains(t, out.String(), "roku_toy remote: den")
+	require.Contains(t, out.String(), "x is not bound")
+}
@@ -0,0 +1,86 @@
+package roku
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ECPPort is the port the External Control Protocol listens on.
+const ECPPort = "8060"
+
+// IsAddress reports whether s looks like an IP address, a dotted host name
+// or a URL rather than a USN or alias. Single-label host names are ambiguous,
+// so they have to be given as URLs (http://roku-den:8060/). Anything with
+// characters a host name can't have, such as "Mr. Bedroom" or
+// "Kids: Upstairs", is a name. Callers should still look for an alias of
+// that name first, as "den.tv" could be either.
+func IsAddress(s string) bool {
+	if strings.Contains(s, "://") {
+		return true
+	}
+	if _, err := netip.ParseAddr(strings.Trim(s, "[]")); err == nil {
+		return true
+	}
+	host := s
+	if h, port, err := net.SplitHostPort(s); err == nil {
+		if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+			return false
+		}
+		if _, err := netip.ParseAddr(h); err == nil {
+			return true
+		}
+		host = h
+	}
+	if host == "" || strings.IndexFunc(host, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '.')
+	}) >= 0 {
+		return false
+	}
+	return strings.Contains(host, ".") || host != s
+}
+
+// ParseAddress turns an IP, host name, host:port or URL into an ECP base
+// URL, filling in the http scheme and port 8060 where missing.
+func ParseAddress(s string) (*url.URL, error) {
+	if ip, err := netip.ParseAddr(s); err == nil && ip.Is6() {
+		s = "[" + strings.Replace(s, "%", "%25", 1) + "]"
+	}
+	if !strings.Contains(s, "://") {
+		s = "http://" + s
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse address '%s': %w", s, err)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("address '%s' has no host", s)
+	}
+	if u.Port() == "" {
+		u.Host = net.JoinHostPort(u.Hostname(), ECPPort)
+	}
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	return u, nil
+}
+
+// FromAddress builds a Device for a known address without SSDP. The USN is
+// filled in from /query/device-info, which also confirms a Roku is there.
+func FromAddress(ctx context.Context, addr string) (*Device, error) {
+	loc, err := ParseAddress(addr)
+	if err != nil {
+		return nil, err
+	}
+	dev := &Device{Location: loc}
+	info, err := dev.QueryDeviceInfo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("no roku at %s: %w", loc, err)
+	}
+	dev.USN = info.SerialNumber
+	return dev, nil
+}
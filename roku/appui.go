@@ -0,0 +1,153 @@
+package roku
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/dangermike/roku_toy/logging"
+)
+
+// UINode is a single element of the SceneGraph tree returned by
+// /query/app-ui. Only dev-mode devices answer that query.
+type UINode struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr `xml:",any,attr"`
+	Children []*UINode  `xml:",any"`
+	Parent   *UINode    `xml:"-"`
+}
+
+// Rect is the position and size of a UINode as reported in its bounds
+// attribute.
+type Rect struct {
+	X, Y, W, H float64
+}
+
+func (r Rect) CenterX() float64 { return r.X + r.W/2 }
+func (r Rect) CenterY() float64 { return r.Y + r.H/2 }
+
+func (n *UINode) Tag() string {
+	return n.XMLName.Local
+}
+
+func (n *UINode) Attr(name string) (string, bool) {
+	for _, a := range n.Attrs {
+		if a.Name.Local == name {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+func (n *UINode) IsFocused() bool {
+	v, _ := n.Attr("focused")
+	return v == "true"
+}
+
+// Bounds parses the "{x, y, w, h}" bounds attribute.
+func (n *UINode) Bounds() (Rect, bool) {
+	v, ok := n.Attr("bounds")
+	if !ok {
+		return Rect{}, false
+	}
+	parts := strings.Split(strings.Trim(v, "{} "), ",")
+	if len(parts) != 4 {
+		return Rect{}, false
+	}
+	var vals [4]float64
+	for i, p := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return Rect{}, false
+		}
+		vals[i] = f
+	}
+	return Rect{vals[0], vals[1], vals[2], vals[3]}, true
+}
+
+// Walk visits n and all of its descendants depth first. Returning false from
+// fn stops the walk.
+func (n *UINode) Walk(fn func(*UINode) bool) bool {
+	if !fn(n) {
+		return false
+	}
+	for _, c := range n.Children {
+		if !c.Walk(fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// Path returns a slash-separated list of tag names and child indexes from the
+// root to n. It is stable as long as the tree shape does not change.
+func (n *UINode) Path() string {
+	var parts []string
+	for c := n; c.Parent != nil; c = c.Parent {
+		idx := 0
+		for i, s := range c.Parent.Children {
+			if s == c {
+				idx = i
+				break
+			}
+		}
+		parts = append(parts, c.Tag()+"["+strconv.Itoa(idx)+"]")
+	}
+	var sb strings.Builder
+	for i := len(parts) - 1; i >= 0; i-- {
+		sb.WriteByte('/')
+		sb.WriteString(parts[i])
+	}
+	return sb.String()
+}
+
+func (n *UINode) String() string {
+	var sb strings.Builder
+	sb.WriteString(n.Tag())
+	for _, a := range n.Attrs {
+		fmt.Fprintf(&sb, " %s=%q", a.Name.Local, a.Value)
+	}
+	return sb.String()
+}
+
+func (rd *Device) QueryAppUI(ctx context.Context) (*UINode, error) {
+	log := logging.FromContext(ctx)
+	log.Debug("getting app ui")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rd.Location.JoinPath("query", "app-ui").String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get app ui from roku: %w", err)
+	}
+	if resp.StatusCode != 200 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to get app ui from roku: %s", resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get body from from roku app-ui response: %w", err)
+	}
+	log.Debug("got app ui")
+	return parseAppUI(body)
+}
+
+func parseAppUI(data []byte) (*UINode, error) {
+	root := &UINode{}
+	if err := xml.Unmarshal(data, root); err != nil {
+		return nil, fmt.Errorf("failed to extract ui tree from xml response: %w", err)
+	}
+	root.Walk(func(n *UINode) bool {
+		for _, c := range n.Children {
+			c.Parent = n
+		}
+		return true
+	})
+	return root, nil
+}
@@ -52,6 +52,7 @@ func (rd *Device) QueryApps(ctx context.Context) ([]App, error) {
 		return nil, fmt.Errorf("failed to get apps from roku: %w", err)
 	}
 	if resp.StatusCode != 200 {
+		resp.Body.Close()
 		return nil, fmt.Errorf("failed to get apps from roku: %s", resp.Status)
 	}
 	body, err := io.ReadAll(resp.Body)
@@ -76,6 +77,7 @@ func (rd *Device) ActiveApp(ctx context.Context) (App, error) {
 		return app, fmt.Errorf("failed to get active app from roku: %w", err)
 	}
 	if resp.StatusCode != 200 {
+		resp.Body.Close()
 		return app, fmt.Errorf("failed to get get active app from roku: %s", resp.Status)
 	}
 	body, err := io.ReadAll(resp.Body)
@@ -97,12 +99,20 @@ func (rd *Device) ActiveApp(ctx context.Context) (App, error) {
 }
 
 func (rd *Device) Launch(ctx context.Context, id string) error {
+	return rd.LaunchWith(ctx, id, nil)
+}
+
+// LaunchWith launches an app with deep link parameters such as contentId and
+// mediaType.
+func (rd *Device) LaunchWith(ctx context.Context, id string, params url.Values) error {
 	if id == "0" {
 		return rd.Home(ctx)
 	}
 	log := logging.FromContext(ctx)
 	log.Debug("setting channel", zap.String("channel_id", id))
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rd.Location.JoinPath("launch", id).String(), nil)
+	u := rd.Location.JoinPath("launch", id)
+	u.RawQuery = params.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), nil)
 	if err != nil {
 		return err
 	}
@@ -110,6 +120,7 @@ func (rd *Device) Launch(ctx context.Context, id string) error {
 	if err != nil {
 		return fmt.Errorf("failed to launch app %s: %w", id, err)
 	}
+	resp.Body.Close()
 	// 200 successful channel change
 	// 204 channel already set
 	if resp.StatusCode != 200 && resp.StatusCode != 204 {
@@ -164,18 +175,37 @@ func (rd *Device) LaunchByName(ctx context.Context, name string) error {
 func (rd *Device) Home(ctx context.Context) error {
 	log := logging.FromContext(ctx)
 	log.Debug("setting channel home")
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rd.Location.JoinPath("keypress", "home").String(), nil)
+	if err := rd.Keypress(ctx, "home"); err != nil {
+		return err
+	}
+	log.Debug("set channel home")
+	return nil
+}
+
+// LiteralKey is the key that types r into a text field, escaped for
+// Keypress so characters like '/' and '%' arrive intact.
+func LiteralKey(r rune) string {
+	return "Lit_" + url.PathEscape(string(r))
+}
+
+// Keypress sends a single key (e.g. "up", "select", "Lit_a") to the device as
+// a press-and-release.
+func (rd *Device) Keypress(ctx context.Context, key string) error {
+	log := logging.FromContext(ctx)
+	log.Debug("sending keypress", zap.String("key", key))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rd.Location.JoinPath("keypress", key).String(), nil)
 	if err != nil {
 		return err
 	}
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to send 'home' keypress: %w", err)
+		return fmt.Errorf("failed to send '%s' keypress: %w", key, err)
 	}
+	resp.Body.Close()
 	if resp.StatusCode != 200 {
-		return fmt.Errorf("failed to send 'home' keypress: %s", resp.Status)
+		return fmt.Errorf("failed to send '%s' keypress: %s", key, resp.Status)
 	}
-	log.Debug("set channel home")
+	log.Debug("sent keypress", zap.String("key", key))
 	return nil
 }
 
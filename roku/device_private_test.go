@@ -1,10 +1,21 @@
 package roku
 
 import (
+	"context"
 	"encoding/xml"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/dangermike/roku_toy/logging"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
 )
 
 func TestAppParse(t *testing.T) {
@@ -60,3 +71,290 @@ func TestAppsParse(t *testing.T) {
 		{ID: "683311", Version: "10.3.17", Name: "Live TV Guide"},
 	}, apps)
 }
+
+func TestAppUIParse(t *testing.T) {
+	uiXML := `<?xml version="1.0" encoding="UTF-8" ?>
+<app-ui>
+	<topscreen>
+		<plugin id="dev" name="Profiles"/>
+		<screen focused="true" type="RSGScreen">
+			<Scene bounds="{0, 0, 1920, 1080}" focused="true">
+				<RowList bounds="{100, 200, 1720, 300}" name="profiles">
+					<Group bounds="{0, 0, 300, 300}" focused="true"><Label text="Kids"/></Group>
+				</RowList>
+			</Scene>
+		</screen>
+	</topscreen>
+</app-ui>`
+
+	root, err := parseAppUI([]byte(uiXML))
+	require.NoError(t, err)
+	require.Equal(t, "app-ui", root.Tag())
+
+	var labels []*UINode
+	root.Walk(func(n *UINode) bool {
+		if n.Tag() == "Label" {
+			labels = append(labels, n)
+		}
+		return true
+	})
+	require.Len(t, labels, 1)
+	label := labels[0]
+	require.True(t, label.Parent.IsFocused())
+	require.Equal(t, "/topscreen[0]/screen[1]/Scene[0]/RowList[0]/Group[0]/Label[0]", label.Path())
+
+	b, ok := label.Parent.Parent.Bounds()
+	require.True(t, ok)
+	require.Equal(t, Rect{100, 200, 1720, 300}, b)
+	_, ok = label.Bounds()
+	require.False(t, ok)
+}
+
+func TestDigestAuth(t *testing.T) {
+	chal, err := parseDigestChallenge(`Digest qop="auth", realm="rokudev", nonce="1712345678", opaque="a,b"`)
+	require.NoError(t, err)
+	require.Equal(t, digestChallenge{realm: "rokudev", nonce: "1712345678", opaque: "a,b", qop: "auth"}, chal)
+
+	_, err = parseDigestChallenge(`Basic realm="x"`)
+	require.Error(t, err)
+
+	// RFC 2069 style, without qop, is deterministic
+	chal.qop = ""
+	chal.opaque = ""
+	require.Equal(t,
+		`Digest username="rokudev", realm="rokudev", nonce="1712345678", uri="/plugin_inspect", response="`+
+			md5hex(md5hex("rokudev:rokudev:secret")+":1712345678:"+md5hex("POST:/plugin_inspect"))+`"`,
+		chal.authorize("POST", "/plugin_inspect", "rokudev", "secret"),
+	)
+}
+
+func TestDigestTransport(t *testing.T) {
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		if r.Header.Get("Authorization") == "" {
+			w.Header().Set("WWW-Authenticate", `Digest qop="auth", realm="rokudev", nonce="1712345678"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/plugin_inspect", strings.NewReader("mysubmit=Screenshot"))
+	require.NoError(t, err)
+	resp, err := devClient("secret").Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, []string{"mysubmit=Screenshot", "mysubmit=Screenshot"}, bodies, "the body is sent again with the credentials")
+	require.Empty(t, req.Header.Get("Authorization"), "the caller's request is not modified")
+}
+
+func TestIfaceAllowed(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		opts SSDPOptions
+		exp  bool
+	}{
+		{"eth0", SSDPOptions{}, true},
+		{"eth0", SSDPOptions{Interfaces: []string{"eth0"}}, true},
+		{"wlan0", SSDPOptions{Interfaces: []string{"eth0"}}, false},
+		{"docker0", SSDPOptions{ExcludeInterfaces: []string{"docker*", "br-*"}}, false},
+		{"br-1a2b", SSDPOptions{ExcludeInterfaces: []string{"docker*", "br-*"}}, false},
+		{"en0", SSDPOptions{ExcludeInterfaces: []string{"docker*", "br-*"}}, true},
+		{"en1", SSDPOptions{Interfaces: []string{"en*"}, ExcludeInterfaces: []string{"en1"}}, false},
+	} {
+		require.Equal(t, test.exp, ifaceAllowed(test.name, test.opts), "%s %+v", test.name, test.opts)
+	}
+}
+
+func TestParseNotify(t *testing.T) {
+	alive := "NOTIFY * HTTP/1.1\r\n" +
+		"Host: 239.255.255.250:1900\r\n" +
+		"NT: roku:ecp\r\n" +
+		"NTS: ssdp:alive\r\n" +
+		"USN: uuid:roku:ecp:ABCDEFGHIJKL\r\n" +
+		"Location: http://192.168.1.176:8060/\r\n" +
+		"Cache-Control: max-age=3600\r\n" +
+		"device-group.roku.com: 0123456789ABCDEF\r\n\r\n"
+	dev, err := parseNotify([]byte(alive))
+	require.NoError(t, err)
+	require.Equal(t, "ABCDEFGHIJKL", dev.USN)
+	require.Equal(t, "http://192.168.1.176:8060/", dev.Location.String())
+	require.Equal(t, time.Hour, dev.BroadcastInterval)
+	require.Equal(t, "0123456789ABCDEF", dev.DeviceGroup)
+
+	bye := "NOTIFY * HTTP/1.1\r\n" +
+		"Host: 239.255.255.250:1900\r\n" +
+		"NT: roku:ecp\r\n" +
+		"NTS: ssdp:byebye\r\n" +
+		"USN: uuid:roku:ecp:ABCDEFGHIJKL\r\n\r\n"
+	dev, err = parseNotify([]byte(bye))
+	require.ErrorIs(t, err, errByeBye)
+	require.Equal(t, "ABCDEFGHIJKL", dev.USN)
+
+	other := "NOTIFY * HTTP/1.1\r\n" +
+		"Host: 239.255.255.250:1900\r\n" +
+		"NT: upnp:rootdevice\r\n" +
+		"NTS: ssdp:alive\r\n\r\n"
+	_, err = parseNotify([]byte(other))
+	require.ErrorIs(t, err, ErrNotRoku)
+}
+
+func TestPresence(t *testing.T) {
+	loc := func(s string) *url.URL {
+		u, err := url.Parse(s)
+		require.NoError(t, err)
+		return u
+	}
+	now := time.Unix(1700000000, 0)
+	p := newPresence(PreferIPv4)
+
+	a := &Device{USN: "A", Location: loc("http://10.0.0.1:8060/"), BroadcastInterval: time.Minute}
+	evs := p.alive(a, now)
+	require.Len(t, evs, 1)
+	require.Equal(t, EventArrive, evs[0].Type)
+
+	require.Empty(t, p.alive(a, now.Add(30*time.Second)), "renewal is not an event")
+
+	moved := &Device{USN: "A", Location: loc("http://10.0.0.2:8060/"), BroadcastInterval: time.Minute}
+	evs = p.alive(moved, now.Add(40*time.Second))
+	require.Len(t, evs, 1)
+	require.Equal(t, EventMove, evs[0].Type)
+	require.Equal(t, "10.0.0.1:8060", evs[0].Previous.Host)
+
+	v6 := &Device{USN: "A", Location: loc("http://[fe80::1%25eth0]:8060/"), BroadcastInterval: time.Minute}
+	require.Empty(t, p.alive(v6, now.Add(40*time.Second)), "the same device on the other family is not a move")
+
+	b := &Device{USN: "B", Location: loc("http://10.0.0.3:8060/"), BroadcastInterval: time.Minute}
+	p.alive(b, now)
+	evs = p.bye("B")
+	require.Len(t, evs, 1)
+	require.Equal(t, EventLeave, evs[0].Type)
+	require.False(t, evs[0].Expired)
+	require.Empty(t, p.bye("B"))
+
+	require.Empty(t, p.expire(now.Add(90*time.Second)))
+	evs = p.expire(now.Add(101 * time.Second))
+	require.Len(t, evs, 1)
+	require.Equal(t, EventLeave, evs[0].Type)
+	require.True(t, evs[0].Expired)
+}
+
+func TestAddress(t *testing.T) {
+	for _, test := range []struct {
+		input string
+		isAdr bool
+		exp   string
+	}{
+		{"192.168.1.50", true, "http://192.168.1.50:8060/"},
+		{"192.168.1.50:8061", true, "http://192.168.1.50:8061/"},
+		{"roku-den.lan", true, "http://roku-den.lan:8060/"},
+		{"http://roku-den:8060/", true, "http://roku-den:8060/"},
+		{"http://roku-den", true, "http://roku-den:8060/"},
+		{"fe80::1", true, "http://[fe80::1]:8060/"},
+		{"[fe80::1]:8060", true, "http://[fe80::1]:8060/"},
+		{"fe80::1%eth0", true, "http://[fe80::1%25eth0]:8060/"},
+		{"roku-den:8060", true, "http://roku-den:8060/"},
+		{"YH00AB123456", false, "http://YH00AB123456:8060/"},
+		{"living_room", false, "http://living_room:8060/"},
+		{"Mr. Bedroom", false, ""},
+		{"Kids: Upstairs", false, ""},
+		{"Kids:Upstairs", false, ""},
+		{"den:", false, ""},
+	} {
+		t.Run(test.input, func(t *testing.T) {
+			require.Equal(t, test.isAdr, IsAddress(test.input))
+			if test.exp == "" {
+				return
+			}
+			u, err := ParseAddress(test.input)
+			require.NoError(t, err)
+			require.Equal(t, test.exp, u.String())
+		})
+	}
+}
+
+func TestDevURL(t *testing.T) {
+	for loc, exp := range map[string]string{
+		"http://192.168.1.50:8060/":     "http://192.168.1.50:80/plugin_inspect",
+		"http://roku-den.lan:8060/":     "http://roku-den.lan:80/plugin_inspect",
+		"http://[fe80::1%25eth0]:8060/": "http://[fe80::1%25eth0]:80/plugin_inspect",
+		"http://[2001:db8::5]:8060/":    "http://[2001:db8::5]:80/plugin_inspect",
+	} {
+		u, err := url.Parse(loc)
+		require.NoError(t, err)
+		got := (&Device{Location: u}).devURL().JoinPath("plugin_inspect").String()
+		require.Equal(t, exp, got, loc)
+		_, err = url.Parse(got)
+		require.NoError(t, err, got)
+	}
+}
+
+func TestScanHosts(t *testing.T) {
+	hosts := scanHosts(netip.MustParsePrefix("192.168.1.0/24"))
+	require.Len(t, hosts, 254)
+	require.Equal(t, "192.168.1.1", hosts[0].String())
+	require.Equal(t, "192.168.1.254", hosts[253].String())
+
+	hosts = scanHosts(netip.MustParsePrefix("10.0.0.4/31"))
+	require.Equal(t, []netip.Addr{netip.MustParseAddr("10.0.0.4"), netip.MustParseAddr("10.0.0.5")}, hosts)
+
+	require.Equal(t, "10.1.255.255", lastAddr(netip.MustParsePrefix("10.1.0.0/16")).String())
+}
+
+func TestScanPrefixes(t *testing.T) {
+	ctx := logging.NewContext(context.Background(), zap.NewNop())
+	prefixes, err := scanPrefixes(ctx, ScanOptions{CIDRs: []string{"192.168.1.77/24", "10.0.0.0/30"}})
+	require.NoError(t, err)
+	require.Equal(t, []netip.Prefix{netip.MustParsePrefix("192.168.1.0/24"), netip.MustParsePrefix("10.0.0.0/30")}, prefixes)
+
+	_, err = scanPrefixes(ctx, ScanOptions{CIDRs: []string{"10.0.0.0/8"}})
+	require.Error(t, err)
+	_, err = scanPrefixes(ctx, ScanOptions{CIDRs: []string{"nope"}})
+	require.Error(t, err)
+}
+
+func TestSearchRequest(t *testing.T) {
+	require.Equal(t,
+		"M-SEARCH * HTTP/1.1\r\nHost: 239.255.255.250:1900\r\nMan: \"ssdp:discover\"\r\nST: roku:ecp\r\nMX: 3\r\n\r\n",
+		string(searchRequest("239.255.255.250:1900", 3)),
+	)
+	require.Contains(t,
+		string(searchRequest(hostHeader(&net.UDPAddr{IP: net.ParseIP("ff02::c"), Port: 1900, Zone: "eth0"}), 1)),
+		"\r\nHost: [FF02::C]:1900\r\n",
+	)
+}
+
+func TestAddZone(t *testing.T) {
+	for _, tc := range []struct{ in, want string }{
+		{"http://[fe80::1]:8060/", "http://[fe80::1%25eth0]:8060/"},
+		{"http://[fe80::1%25wlan0]:8060/", "http://[fe80::1%25wlan0]:8060/"},
+		{"http://[2001:db8::1]:8060/", "http://[2001:db8::1]:8060/"},
+		{"http://192.168.1.5:8060/", "http://192.168.1.5:8060/"},
+	} {
+		loc, err := url.Parse(tc.in)
+		require.NoError(t, err)
+		addZone(loc, "eth0")
+		require.Equal(t, tc.want, loc.String())
+	}
+}
+
+func TestIPModePreferred(t *testing.T) {
+	v4 := &Device{Location: &url.URL{Scheme: "http", Host: "192.168.1.5:8060"}}
+	v6 := &Device{Location: &url.URL{Scheme: "http", Host: "[fe80::1%25eth0]:8060"}}
+	require.True(t, PreferIPv4.preferred(v4))
+	require.False(t, PreferIPv4.preferred(v6))
+	require.True(t, PreferIPv6.preferred(v6))
+	require.False(t, PreferIPv6.preferred(v4))
+	require.True(t, IPv6Only.preferred(v4))
+
+	_, err := ParseIPMode("ipv5")
+	require.Error(t, err)
+	m, err := ParseIPMode("")
+	require.NoError(t, err)
+	require.Equal(t, IPv4Only, m)
+	require.Equal(t, IPv4Only, SSDPOptions{}.withDefaults().IP, "IPv6 is opt-in")
+}
@@ -1,9 +1,15 @@
 package roku_test
 
 import (
+	"context"
+	"net/http"
+	"net/url"
 	"testing"
+	"time"
 
+	"github.com/dangermike/roku_toy/emulator"
 	"github.com/dangermike/roku_toy/roku"
+	"github.com/dangermike/roku_toy/rokutest"
 
 	"github.com/stretchr/testify/require"
 )
@@ -53,3 +59,106 @@ func TestFuzzyMatch(t *testing.T) {
 		})
 	}
 }
+
+func TestFindQueriedApp(t *testing.T) {
+	srv := rokutest.NewServer(t)
+	apps, err := srv.Device.QueryApps(context.Background())
+	require.NoError(t, err)
+	srv.Device.SetApps(apps)
+
+	app := srv.Device.FindApp("spotfy")
+	require.NotNil(t, app)
+	require.Equal(t, "22297", app.ID)
+	require.Nil(t, srv.Device.FindApp("xyzzy"))
+}
+
+func TestQueryApps(t *testing.T) {
+	srv := rokutest.NewServer(t)
+	apps, err := srv.Device.QueryApps(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, rokutest.Apps, apps)
+
+	srv.Fail(http.MethodGet, "/query/apps", http.StatusServiceUnavailable, 1)
+	_, err = srv.Device.QueryApps(context.Background())
+	require.ErrorContains(t, err, "503")
+	_, err = srv.Device.QueryApps(context.Background())
+	require.NoError(t, err, "failure only injected once")
+}
+
+func TestActiveApp(t *testing.T) {
+	srv := rokutest.NewServer(t, emulator.DeviceConfig{ActiveApp: "837"})
+	app, err := srv.Device.ActiveApp(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, roku.App{ID: "837", Version: "2.20.110005159", Name: "YouTube"}, app)
+
+	srv.Respond("GET /query/active-app", http.StatusOK, `<active-app><app>Roku</app><app>Extra</app></active-app>`)
+	_, err = srv.Device.ActiveApp(context.Background())
+	require.ErrorContains(t, err, "contained 2 apps")
+}
+
+func TestLaunch(t *testing.T) {
+	ctx := context.Background()
+	srv := rokutest.NewServer(t)
+	require.NoError(t, srv.Device.Launch(ctx, "12"))
+	require.Equal(t, "12", srv.State.ActiveApp())
+	require.NoError(t, srv.Device.Launch(ctx, "12"), "204 when already running")
+
+	require.NoError(t, srv.Device.LaunchWith(ctx, "837", url.Values{"contentId": {"dQw4w9WgXcQ"}, "mediaType": {"movie"}}))
+	srv.AssertLaunched(t, "837", url.Values{"contentId": {"dQw4w9WgXcQ"}})
+
+	require.Error(t, srv.Device.Launch(ctx, "424242"))
+
+	require.NoError(t, srv.Device.Launch(ctx, "0"))
+	srv.AssertKeys(t, "home")
+	srv.AssertNotCalled(t, http.MethodPost, "/launch/0")
+}
+
+func TestLaunchByName(t *testing.T) {
+	srv := rokutest.NewServer(t)
+	require.NoError(t, srv.Device.LaunchByName(context.Background(), "plex"))
+	srv.AssertLaunched(t, "13535", nil)
+
+	err := srv.Device.LaunchByName(context.Background(), "xyzzy")
+	require.Equal(t, roku.ErrApplicationNotFound("xyzzy"), err)
+}
+
+func TestHome(t *testing.T) {
+	srv := rokutest.NewServer(t, emulator.DeviceConfig{ActiveApp: "12"})
+	require.NoError(t, srv.Device.Home(context.Background()))
+	require.Equal(t, "", srv.State.ActiveApp())
+	srv.AssertKeys(t, "home")
+
+	srv.Fail("", "", http.StatusInternalServerError, -1)
+	require.Error(t, srv.Device.Home(context.Background()))
+}
+
+func TestTimeout(t *testing.T) {
+	srv := rokutest.NewServer(t)
+	srv.SetLatency(time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := srv.Device.QueryApps(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestQueryMediaPlayer(t *testing.T) {
+	srv := rokutest.NewServer(t, emulator.DeviceConfig{
+		ActiveApp: "12",
+		Media:     emulator.Media{State: "play", Position: 90 * time.Second, Duration: 45 * time.Minute},
+	})
+	mp, err := srv.Device.QueryMediaPlayer(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, roku.MediaPlayer{State: "play", AppID: "12", AppName: "Netflix", Position: 90 * time.Second, Duration: 45 * time.Minute}, mp)
+
+	srv.Respond("GET /query/media-player", http.StatusOK, `<player error="true" state="close"/>`)
+	_, err = srv.Device.QueryMediaPlayer(context.Background())
+	require.ErrorContains(t, err, "reported an error")
+}
+
+func TestLiteralKey(t *testing.T) {
+	srv := rokutest.NewServer(t)
+	for _, r := range "a /%?é" {
+		require.NoError(t, srv.Device.Keypress(context.Background(), roku.LiteralKey(r)))
+	}
+	srv.AssertKeys(t, "Lit_a", "Lit_ ", "Lit_/", "Lit_%", "Lit_?", "Lit_é")
+}
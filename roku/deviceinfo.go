@@ -0,0 +1,60 @@
+package roku
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/dangermike/roku_toy/logging"
+)
+
+// DeviceInfo is the subset of /query/device-info that roku_toy uses. The
+// serial number is the same value SSDP reports as the USN.
+type DeviceInfo struct {
+	UDN                string `xml:"udn"`
+	SerialNumber       string `xml:"serial-number"`
+	DeviceID           string `xml:"device-id"`
+	VendorName         string `xml:"vendor-name"`
+	ModelName          string `xml:"model-name"`
+	ModelNumber        string `xml:"model-number"`
+	FriendlyDeviceName string `xml:"friendly-device-name"`
+	UserDeviceName     string `xml:"user-device-name"`
+	DefaultDeviceName  string `xml:"default-device-name"`
+	SoftwareVersion    string `xml:"software-version"`
+	SoftwareBuild      string `xml:"software-build"`
+	WifiMAC            string `xml:"wifi-mac"`
+	EthernetMAC        string `xml:"ethernet-mac"`
+	NetworkType        string `xml:"network-type"`
+	PowerMode          string `xml:"power-mode"`
+	IsTV               bool   `xml:"is-tv"`
+}
+
+func (rd *Device) QueryDeviceInfo(ctx context.Context) (DeviceInfo, error) {
+	log := logging.FromContext(ctx)
+	log.Debug("getting device info")
+	var info DeviceInfo
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rd.Location.JoinPath("query", "device-info").String(), nil)
+	if err != nil {
+		return info, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return info, fmt.Errorf("failed to get device info from roku: %w", err)
+	}
+	if resp.StatusCode != 200 {
+		resp.Body.Close()
+		return info, fmt.Errorf("failed to get device info from roku: %s", resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return info, fmt.Errorf("failed to get body from from roku device-info response: %w", err)
+	}
+	if err := xml.Unmarshal(body, &info); err != nil {
+		return info, fmt.Errorf("failed to parse roku device-info response: %w", err)
+	}
+	log.Debug("got device info")
+	return info, nil
+}
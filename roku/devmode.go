@@ -0,0 +1,98 @@
+package roku
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+
+	"github.com/dangermike/roku_toy/logging"
+	"go.uber.org/zap"
+)
+
+// DevUser is the fixed user name of the developer web server.
+const DevUser = "rokudev"
+
+var rxScreenshotPath = regexp.MustCompile(`pkgs/dev\.(jpg|png)`)
+
+// devURL is the developer web server, which listens on port 80 of the same
+// host as ECP. IPv6 hosts keep their brackets and zone.
+func (rd *Device) devURL() *url.URL {
+	return &url.URL{Scheme: "http", Host: net.JoinHostPort(rd.Location.Hostname(), "80")}
+}
+
+func devClient(password string) *http.Client {
+	return &http.Client{Transport: &digestTransport{
+		username: DevUser,
+		password: password,
+		base:     http.DefaultTransport,
+	}}
+}
+
+// Screenshot asks the developer web server to capture the screen of the
+// running dev channel and returns the decoded image. The device must be in
+// developer mode with a sideloaded channel running.
+func (rd *Device) Screenshot(ctx context.Context, password string) (image.Image, error) {
+	log := logging.FromContext(ctx)
+	client := devClient(password)
+
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	if err := mw.WriteField("mysubmit", "Screenshot"); err != nil {
+		return nil, err
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	log.Debug("requesting screenshot")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rd.devURL().JoinPath("plugin_inspect").String(), bytes.NewReader(form.Bytes()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request screenshot: %w", err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get body from screenshot response: %w", err)
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("failed to request screenshot: %s", resp.Status)
+	}
+	imgPath := "pkgs/dev.jpg"
+	if m := rxScreenshotPath.Find(body); m != nil {
+		imgPath = string(m)
+	}
+
+	log.Debug("downloading screenshot", zap.String("path", imgPath))
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, rd.devURL().JoinPath(imgPath).String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err = client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download screenshot: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("failed to download screenshot: %s", resp.Status)
+	}
+	img, _, err := image.Decode(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode screenshot: %w", err)
+	}
+	log.Debug("got screenshot", zap.Stringer("size", img.Bounds().Size()))
+	return img, nil
+}
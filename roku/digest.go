@@ -0,0 +1,141 @@
+package roku
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// digestTransport answers the HTTP digest challenge used by the developer
+// web server (user "rokudev"). Only MD5 with qop=auth is supported, which is
+// all the Roku offers.
+type digestTransport struct {
+	username string
+	password string
+	base     http.RoundTripper
+}
+
+// RoundTrip sends req and, if the server asks for digest authentication,
+// sends it again with credentials. The body is buffered so it can be sent
+// twice; req itself is not modified, as http.RoundTripper requires.
+func (t *digestTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	first := req.Clone(req.Context())
+	if req.Body != nil {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		first.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+		first.Body, _ = first.GetBody()
+	}
+
+	resp, err := t.base.RoundTrip(first)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	chal, err := parseDigestChallenge(resp.Header.Get("WWW-Authenticate"))
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	retry := first.Clone(req.Context())
+	if first.GetBody != nil {
+		retry.Body, _ = first.GetBody()
+	}
+	retry.Header.Set("Authorization", chal.authorize(req.Method, req.URL.RequestURI(), t.username, t.password))
+	return t.base.RoundTrip(retry)
+}
+
+type digestChallenge struct {
+	realm  string
+	nonce  string
+	opaque string
+	qop    string
+}
+
+func parseDigestChallenge(h string) (digestChallenge, error) {
+	var c digestChallenge
+	rest, ok := strings.CutPrefix(h, "Digest ")
+	if !ok {
+		return c, fmt.Errorf("unsupported authentication challenge '%s'", h)
+	}
+	for _, part := range splitDigestParams(rest) {
+		k, v, ok := strings.Cut(part, "=")
+		if !ok {
+			continue
+		}
+		v = strings.Trim(v, `"`)
+		switch strings.ToLower(strings.TrimSpace(k)) {
+		case "realm":
+			c.realm = v
+		case "nonce":
+			c.nonce = v
+		case "opaque":
+			c.opaque = v
+		case "qop":
+			for _, q := range strings.Split(v, ",") {
+				if strings.TrimSpace(q) == "auth" {
+					c.qop = "auth"
+				}
+			}
+		}
+	}
+	if c.nonce == "" {
+		return c, fmt.Errorf("authentication challenge '%s' has no nonce", h)
+	}
+	return c, nil
+}
+
+// splitDigestParams splits on commas that are not inside quotes.
+func splitDigestParams(s string) []string {
+	var parts []string
+	var quoted bool
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			quoted = !quoted
+		case ',':
+			if !quoted {
+				parts = append(parts, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, strings.TrimSpace(s[start:]))
+}
+
+func (c digestChallenge) authorize(method, uri, username, password string) string {
+	ha1 := md5hex(username + ":" + c.realm + ":" + password)
+	ha2 := md5hex(method + ":" + uri)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `Digest username="%s", realm="%s", nonce="%s", uri="%s"`, username, c.realm, c.nonce, uri)
+	if c.qop == "" {
+		fmt.Fprintf(&sb, `, response="%s"`, md5hex(ha1+":"+c.nonce+":"+ha2))
+	} else {
+		cnonce := make([]byte, 8)
+		_, _ = rand.Read(cnonce)
+		cn := hex.EncodeToString(cnonce)
+		const nc = "00000001"
+		fmt.Fprintf(&sb, `, qop=%s, nc=%s, cnonce="%s", response="%s"`, c.qop, nc, cn, md5hex(ha1+":"+c.nonce+":"+nc+":"+cn+":"+c.qop+":"+ha2))
+	}
+	if c.opaque != "" {
+		fmt.Fprintf(&sb, `, opaque="%s"`, c.opaque)
+	}
+	return sb.String()
+}
+
+func md5hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
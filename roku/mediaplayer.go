@@ -0,0 +1,94 @@
+package roku
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dangermike/roku_toy/logging"
+)
+
+// MediaPlayer is the state of the device's media player from
+// /query/media-player. State is one of close, play, pause, stop, buffer or
+// startup. The app is empty while nothing is playing.
+type MediaPlayer struct {
+	State    string
+	AppID    string
+	AppName  string
+	Position time.Duration
+	Duration time.Duration
+	Live     bool
+}
+
+type mediaPlayerXML struct {
+	Error  bool   `xml:"error,attr"`
+	State  string `xml:"state,attr"`
+	Plugin struct {
+		ID   string `xml:"id,attr"`
+		Name string `xml:"name,attr"`
+	} `xml:"plugin"`
+	Position string `xml:"position"`
+	Duration string `xml:"duration"`
+	IsLive   bool   `xml:"is_live"`
+}
+
+func (rd *Device) QueryMediaPlayer(ctx context.Context) (MediaPlayer, error) {
+	log := logging.FromContext(ctx)
+	log.Debug("getting media player")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rd.Location.JoinPath("query", "media-player").String(), nil)
+	if err != nil {
+		return MediaPlayer{}, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return MediaPlayer{}, fmt.Errorf("failed to get media player from roku: %w", err)
+	}
+	if resp.StatusCode != 200 {
+		resp.Body.Close()
+		return MediaPlayer{}, fmt.Errorf("failed to get media player from roku: %s", resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return MediaPlayer{}, fmt.Errorf("failed to get body from roku media-player response: %w", err)
+	}
+	mp, err := parseMediaPlayer(body)
+	if err != nil {
+		return MediaPlayer{}, err
+	}
+	log.Debug("got media player")
+	return mp, nil
+}
+
+func parseMediaPlayer(data []byte) (MediaPlayer, error) {
+	var raw mediaPlayerXML
+	if err := xml.Unmarshal(data, &raw); err != nil {
+		return MediaPlayer{}, fmt.Errorf("failed to parse roku media-player response: %w", err)
+	}
+	if raw.Error {
+		return MediaPlayer{}, errors.New("roku media player reported an error")
+	}
+	return MediaPlayer{
+		State:    raw.State,
+		AppID:    raw.Plugin.ID,
+		AppName:  raw.Plugin.Name,
+		Position: parseMillis(raw.Position),
+		Duration: parseMillis(raw.Duration),
+		Live:     raw.IsLive,
+	}, nil
+}
+
+// parseMillis reads times such as "90000 ms". Anything else is zero.
+func parseMillis(s string) time.Duration {
+	ms, err := strconv.ParseInt(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s), "ms")), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return time.Duration(ms) * time.Millisecond
+}
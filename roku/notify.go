@@ -0,0 +1,281 @@
+package roku
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/dangermike/roku_toy/logging"
+	"go.uber.org/zap"
+)
+
+type EventType int
+
+const (
+	// EventArrive is sent the first time a device is seen.
+	EventArrive EventType = iota
+	// EventLeave is sent when a device says ssdp:byebye or its announcement
+	// expires without being renewed.
+	EventLeave
+	// EventMove is sent when a known device announces a new location.
+	EventMove
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventArrive:
+		return "arrive"
+	case EventLeave:
+		return "leave"
+	case EventMove:
+		return "move"
+	}
+	return fmt.Sprintf("EventType(%d)", int(t))
+}
+
+type Event struct {
+	Type   EventType
+	Device *Device
+	// Previous is the old location of a device that moved.
+	Previous *url.URL
+	// Expired is set on a leave event if the device did not say goodbye.
+	Expired bool
+}
+
+type WatchOptions struct {
+	SSDPOptions
+	// Search sends an M-SEARCH when the watch starts so devices that are
+	// already up are reported without waiting for their next announcement.
+	Search bool
+}
+
+var errByeBye = errors.New("ssdp:byebye")
+
+// Watch joins the SSDP multicast group on every eligible interface and calls
+// cb with arrive, leave and move events for roku:ecp devices until ctx is
+// done or cb returns an error. A device is considered gone if it does not
+// renew its announcement within its Cache-Control max-age.
+func Watch(ctx context.Context, opts WatchOptions, cb func(Event) error) error {
+	log := logging.FromContext(ctx)
+	addrs, err := getLocalAddrs(ctx, opts.SSDPOptions)
+	if err != nil {
+		return fmt.Errorf("failed to get local address: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type notification struct {
+		dev *Device
+		bye bool
+	}
+	notes := make(chan notification)
+	var wg sync.WaitGroup
+	var joined int
+	var groups []*net.UDPAddr
+	var ifaces []net.Interface
+	for _, a := range addrs {
+		for _, g := range a.groups() {
+			groups = append(groups, g)
+			ifaces = append(ifaces, a.iface)
+		}
+	}
+	for i, group := range groups {
+		iface := ifaces[i]
+		network := "udp4"
+		if group.IP.To4() == nil {
+			network = "udp6"
+		}
+		lc, err := net.ListenMulticastUDP(network, &iface, group)
+		if err != nil {
+			log.Debug("failed to join multicast group", zap.String("interface", iface.Name), zap.Stringer("group", group), zap.Error(err))
+			continue
+		}
+		joined++
+		if err := lc.SetReadBuffer(readBufferSize); err != nil {
+			log.Debug("failed to set read buffer", zap.String("interface", iface.Name), zap.Error(err))
+		}
+		stop := context.AfterFunc(ctx, func() { lc.Close() })
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer stop()
+			defer lc.Close()
+			buf := make([]byte, 1<<16)
+			for {
+				cnt, src, err := lc.ReadFromUDP(buf)
+				if err != nil {
+					if ctx.Err() == nil {
+						log.Debug("multicast read failed", zap.String("interface", iface.Name), zap.Error(err))
+					}
+					return
+				}
+				dev, err := parseNotify(buf[:cnt])
+				if err == ErrNotRoku {
+					continue
+				}
+				// every socket joined to the group gets the packet whatever
+				// interface it arrived on, so the zone comes from the source
+				// address, which the kernel scopes to the arrival interface
+				if dev != nil && dev.Location != nil && src.Zone != "" {
+					addZone(dev.Location, src.Zone)
+				}
+				n := notification{dev: dev}
+				if err == errByeBye {
+					n.bye = true
+				} else if err != nil {
+					log.Debug("failed to parse NOTIFY", zap.String("source", src.String()), zap.Error(err))
+					continue
+				}
+				select {
+				case notes <- n:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	if joined == 0 {
+		return errors.New("failed to join the SSDP multicast group on any interface")
+	}
+	if opts.Search {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := SearchSSDP(ctx, opts.SSDPOptions, func(dev *Device) error {
+				select {
+				case notes <- notification{dev: dev}:
+				case <-ctx.Done():
+				}
+				return nil
+			}); err != nil && ctx.Err() == nil {
+				log.Debug("initial search failed", zap.Error(err))
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(notes)
+	}()
+
+	p := newPresence(opts.IP)
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+	emit := func(evs []Event) error {
+		for _, ev := range evs {
+			if err := cb(ev); err != nil {
+				return fmt.Errorf("watch callback returned error: %w", err)
+			}
+		}
+		return nil
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case now := <-tick.C:
+			if err := emit(p.expire(now)); err != nil {
+				return err
+			}
+		case n, ok := <-notes:
+			if !ok {
+				return errors.New("stopped listening for SSDP announcements")
+			}
+			var evs []Event
+			if n.bye {
+				evs = p.bye(n.dev.USN)
+			} else {
+				evs = p.alive(n.dev, time.Now())
+			}
+			if err := emit(evs); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// parseNotify parses a NOTIFY request. For ssdp:byebye only the USN is filled
+// in and errByeBye is returned alongside the device.
+func parseNotify(data []byte) (*Device, error) {
+	req, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SSDP request: %w", err)
+	}
+	if req.Method != "NOTIFY" || req.Header.Get("NT") != "roku:ecp" {
+		return nil, ErrNotRoku
+	}
+	switch req.Header.Get("NTS") {
+	case "ssdp:alive":
+		return rokuDevFromHeader(req.Header)
+	case "ssdp:byebye":
+		return &Device{USN: usnFromHeader(req.Header)}, errByeBye
+	}
+	return nil, fmt.Errorf("unknown NTS '%s'", req.Header.Get("NTS"))
+}
+
+// presence tracks which devices are up and turns announcements into events.
+// A device that announces itself on both address families keeps its location
+// in the preferred one instead of flapping between the two.
+type presence struct {
+	known map[string]*presenceEntry
+	ip    IPMode
+}
+
+type presenceEntry struct {
+	dev     *Device
+	expires time.Time
+}
+
+func newPresence(ip IPMode) *presence {
+	return &presence{known: map[string]*presenceEntry{}, ip: ip}
+}
+
+func (p *presence) alive(dev *Device, now time.Time) []Event {
+	ttl := dev.BroadcastInterval
+	if ttl <= 0 {
+		ttl = 30 * time.Minute
+	}
+	e, ok := p.known[dev.USN]
+	if !ok {
+		p.known[dev.USN] = &presenceEntry{dev: dev, expires: now.Add(ttl)}
+		return []Event{{Type: EventArrive, Device: dev}}
+	}
+	e.expires = now.Add(ttl)
+	if p.ip.preferred(e.dev) && !p.ip.preferred(dev) {
+		return nil
+	}
+	prev := e.dev.Location
+	e.dev = dev
+	if prev != nil && dev.Location != nil && prev.Host != dev.Location.Host {
+		return []Event{{Type: EventMove, Device: dev, Previous: prev}}
+	}
+	return nil
+}
+
+func (p *presence) bye(usn string) []Event {
+	e, ok := p.known[usn]
+	if !ok {
+		return nil
+	}
+	delete(p.known, usn)
+	return []Event{{Type: EventLeave, Device: e.dev}}
+}
+
+func (p *presence) expire(now time.Time) []Event {
+	var evs []Event
+	for usn, e := range p.known {
+		if now.After(e.expires) {
+			delete(p.known, usn)
+			evs = append(evs, Event{Type: EventLeave, Device: e.dev, Expired: true})
+		}
+	}
+	return evs
+}
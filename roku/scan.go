@@ -0,0 +1,205 @@
+package roku
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/dangermike/roku_toy/logging"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxScanHosts keeps a typo like /8 from turning into a 16M host scan.
+	maxScanHosts = 1 << 16
+	// defaultScanBits narrows the subnet of a large interface network so the
+	// default scan finishes in a few seconds.
+	defaultScanBits = 24
+)
+
+type ScanOptions struct {
+	// SSDPOptions selects the interfaces whose subnets are scanned when no
+	// CIDRs are given.
+	SSDPOptions
+	// CIDRs to scan, e.g. "192.168.1.0/24".
+	CIDRs []string
+	// Concurrency is the number of hosts probed at once.
+	Concurrency int
+	// DialTimeout bounds each TCP connection attempt.
+	DialTimeout time.Duration
+}
+
+func (o ScanOptions) withDefaults() ScanOptions {
+	if o.Concurrency <= 0 {
+		o.Concurrency = 64
+	}
+	if o.DialTimeout <= 0 {
+		o.DialTimeout = 500 * time.Millisecond
+	}
+	return o
+}
+
+// Scan finds devices without multicast by connecting to the ECP port of every
+// host in the given CIDRs, or in the subnets of the local interfaces. Hosts
+// that accept the connection are confirmed with /query/device-info. cb is
+// called once per USN and never concurrently; an error from cb stops the
+// scan and is returned.
+func Scan(ctx context.Context, opts ScanOptions, cb func(*Device) error) error {
+	log := logging.FromContext(ctx)
+	opts = opts.withDefaults()
+	prefixes, err := scanPrefixes(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	hosts := make(chan netip.Addr)
+	found := make(chan *Device)
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ip := range hosts {
+				dev := probe(ctx, ip, opts.DialTimeout)
+				if dev == nil {
+					continue
+				}
+				select {
+				case found <- dev:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		defer close(hosts)
+		for _, p := range prefixes {
+			log.Debug("scanning", zap.Stringer("cidr", p))
+			for _, ip := range scanHosts(p) {
+				select {
+				case hosts <- ip:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(found)
+	}()
+
+	seen := map[string]struct{}{}
+	var cbErr error
+	for dev := range found {
+		if cbErr != nil {
+			continue
+		}
+		if _, ok := seen[dev.USN]; ok {
+			continue
+		}
+		seen[dev.USN] = struct{}{}
+		log.Debug("found roku device", zap.String("USN", dev.USN), zap.String("URL", dev.Location.String()))
+		if err := cb(dev); err != nil {
+			cbErr = fmt.Errorf("scan callback returned error: %w", err)
+			cancel()
+		}
+	}
+	if cbErr != nil {
+		return cbErr
+	}
+	return ctx.Err()
+}
+
+// probe returns the device at ip, or nil if there is no Roku there.
+func probe(ctx context.Context, ip netip.Addr, timeout time.Duration) *Device {
+	addr := net.JoinHostPort(ip.String(), ECPPort)
+	d := net.Dialer{Timeout: timeout}
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil
+	}
+	conn.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, 2*timeout)
+	defer cancel()
+	dev, err := FromAddress(ctx, addr)
+	if err != nil {
+		logging.FromContext(ctx).Debug("port open but not a roku", zap.String("address", addr), zap.Error(err))
+		return nil
+	}
+	return dev
+}
+
+func scanPrefixes(ctx context.Context, opts ScanOptions) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, c := range opts.CIDRs {
+		p, err := netip.ParsePrefix(c)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR '%s': %w", c, err)
+		}
+		prefixes = append(prefixes, p.Masked())
+	}
+	if len(prefixes) == 0 {
+		addrs, err := getLocalAddrs(ctx, opts.SSDPOptions)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get local address: %w", err)
+		}
+		for _, a := range addrs {
+			ip, ok := netip.AddrFromSlice(a.addr.IP.To4())
+			if !ok {
+				continue
+			}
+			ones, _ := a.addr.Mask.Size()
+			prefixes = append(prefixes, netip.PrefixFrom(ip, max(ones, defaultScanBits)).Masked())
+		}
+	}
+	var total int
+	for _, p := range prefixes {
+		if !p.Addr().Is4() {
+			return nil, fmt.Errorf("cannot scan %s: only IPv4 subnets can be scanned", p)
+		}
+		total += 1 << (32 - p.Bits())
+	}
+	if total > maxScanHosts {
+		return nil, fmt.Errorf("refusing to scan %d addresses (max %d)", total, maxScanHosts)
+	}
+	if len(prefixes) == 0 {
+		return nil, errors.New("nothing to scan")
+	}
+	return prefixes, nil
+}
+
+// scanHosts lists every address in p except the network and broadcast
+// addresses of subnets that have them.
+func scanHosts(p netip.Prefix) []netip.Addr {
+	first, last := p.Addr(), lastAddr(p)
+	if p.Bits() < 31 {
+		first = first.Next()
+		last = last.Prev()
+	}
+	var hosts []netip.Addr
+	for ip := first; ip.IsValid() && ip.Compare(last) <= 0; ip = ip.Next() {
+		hosts = append(hosts, ip)
+	}
+	return hosts
+}
+
+func lastAddr(p netip.Prefix) netip.Addr {
+	b := p.Addr().As4()
+	hostBits := 32 - p.Bits()
+	for i := 3; i >= 0 && hostBits > 0; i-- {
+		n := min(hostBits, 8)
+		b[i] |= byte(1<<n - 1)
+		hostBits -= n
+	}
+	return netip.AddrFrom4(b)
+}
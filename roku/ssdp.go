@@ -8,11 +8,14 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"net/netip"
 	"net/url"
 	"os"
+	"path"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	_ "embed"
@@ -22,7 +25,8 @@ import (
 )
 
 const (
-	ssdpPort = 1900
+	ssdpPort       = 1900
+	readBufferSize = 1 << 20
 )
 
 //go:embed ssdp_req.txt
@@ -38,38 +42,262 @@ var (
 		IP:   ssdpHost,
 		Port: ssdpPort,
 	}
+
+	// ssdpHosts6 are the link-local and site-local SSDP multicast groups.
+	ssdpHosts6 = []net.IP{
+		net.ParseIP("ff02::c"),
+		net.ParseIP("ff05::c"),
+	}
 )
 
+// IPMode selects the address families used for discovery.
+type IPMode string
+
+const (
+	// IPv4Only searches IPv4 alone. This is the default; IPv6 is opt-in.
+	IPv4Only IPMode = "ipv4"
+	IPv6Only IPMode = "ipv6"
+	// PreferIPv4 searches both families and, when a device answers on both,
+	// reports its IPv4 location.
+	PreferIPv4 IPMode = "prefer-ipv4"
+	// PreferIPv6 searches both families and, when a device answers on both,
+	// reports its IPv6 location.
+	PreferIPv6 IPMode = "prefer-ipv6"
+)
+
+func ParseIPMode(s string) (IPMode, error) {
+	switch m := IPMode(s); m {
+	case IPv4Only, IPv6Only, PreferIPv4, PreferIPv6:
+		return m, nil
+	case "":
+		return IPv4Only, nil
+	}
+	return "", fmt.Errorf("unknown IP mode '%s' (want ipv4, ipv6, prefer-ipv4 or prefer-ipv6)", s)
+}
+
+func (m IPMode) useIPv4() bool { return m != IPv6Only }
+func (m IPMode) useIPv6() bool { return m != IPv4Only }
+
+// preferred reports whether dev's location is in the preferred family. In
+// the single-family modes everything is preferred.
+func (m IPMode) preferred(dev *Device) bool {
+	switch m {
+	case PreferIPv4, PreferIPv6:
+	default:
+		return true
+	}
+	ip, err := netip.ParseAddr(dev.Location.Hostname())
+	if err != nil {
+		return true
+	}
+	return ip.Unmap().Is6() == (m == PreferIPv6)
+}
+
+// SSDPOptions controls which interfaces are searched and for how long.
+// Interface names may be glob patterns such as "docker*". An empty
+// Interfaces list means every eligible interface.
+type SSDPOptions struct {
+	Interfaces        []string
+	ExcludeInterfaces []string
+	// Timeout is how long to wait for responses. Defaults to 2 seconds.
+	Timeout time.Duration
+	// Retransmits is how many times the M-SEARCH is repeated, with doubling
+	// gaps starting at 250ms, in case it is lost.
+	Retransmits int
+	// MX is the number of seconds devices may wait before responding, to
+	// spread out their answers. Defaults to 1.
+	MX int
+	// Expect stops the search as soon as this many devices have responded.
+	// Zero waits for the full Timeout.
+	Expect int
+	// IP selects IPv4, IPv6 or both. Defaults to IPv4Only.
+	IP IPMode
+}
+
+func (o SSDPOptions) withDefaults() SSDPOptions {
+	if o.Timeout <= 0 {
+		o.Timeout = 2 * time.Second
+	}
+	if o.MX <= 0 {
+		o.MX = 1
+	}
+	if o.IP == "" {
+		o.IP = IPv4Only
+	}
+	return o
+}
+
+// firstRetransmit is the gap before the first repeated M-SEARCH. Each later
+// gap is twice as long.
+const firstRetransmit = 250 * time.Millisecond
+
+// SSDP searches for devices on every eligible interface. See SearchSSDP.
 func SSDP(ctx context.Context, cb func(*Device) error) error {
-	log := logging.FromContext(ctx)
-	_, raddr, err := getLocalAddr(ctx)
+	return SearchSSDP(ctx, SSDPOptions{}, cb)
+}
+
+// SearchSSDP sends an M-SEARCH on every interface allowed by opts: to
+// 239.255.255.250 from up, broadcast-capable IPv4 interfaces, and to the
+// link-local and site-local groups ff02::c and ff05::c from up,
+// multicast-capable IPv6 interfaces, as opts.IP selects (IPv4 alone unless
+// IPv6 is asked for). It calls cb once per
+// USN as responses arrive in the family opts.IP prefers; devices that only
+// answered in the other one are reported when the search ends. cb is never
+// called concurrently. If cb returns an error
+// the search stops and the error is returned.
+func SearchSSDP(parent context.Context, opts SSDPOptions, cb func(*Device) error) error {
+	log := logging.FromContext(parent)
+	opts = opts.withDefaults()
+	addrs, err := getLocalAddrs(parent, opts)
 	if err != nil {
 		return fmt.Errorf("failed to get local address: %w", err)
 	}
+
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	found := make(chan *Device)
+	errs := make(chan error, len(addrs))
+	var wg sync.WaitGroup
+	for _, a := range addrs {
+		wg.Add(1)
+		go func(a ifaceAddr) {
+			defer wg.Done()
+			if err := searchIface(ctx, a, opts, found); err != nil {
+				log.Debug("SSDP search failed", zap.String("interface", a.iface.Name), zap.Error(err))
+				errs <- fmt.Errorf("%s: %w", a.iface.Name, err)
+			}
+		}(a)
+	}
+	go func() {
+		wg.Wait()
+		close(found)
+		close(errs)
+	}()
+
+	// answers in the non-preferred family wait until the search is over in
+	// case the preferred one shows up
+	seen := map[string]struct{}{}
+	var pending []*Device
+	var cbErr error
+	report := func(dev *Device) {
+		seen[dev.USN] = struct{}{}
+		if err := cb(dev); err != nil {
+			cbErr = fmt.Errorf("SSDP callback returned error: %w", err)
+			cancel()
+		} else if opts.Expect > 0 && len(seen) >= opts.Expect {
+			log.Debug("found expected number of devices", zap.Int("count", len(seen)))
+			cancel()
+		}
+	}
+	for dev := range found {
+		if cbErr != nil {
+			continue
+		}
+		if _, ok := seen[dev.USN]; ok {
+			log.Debug("duplicate response", zap.String("USN", dev.USN))
+			continue
+		}
+		if !opts.IP.preferred(dev) {
+			pending = append(pending, dev)
+			continue
+		}
+		report(dev)
+	}
+	for _, dev := range pending {
+		if cbErr != nil || (opts.Expect > 0 && len(seen) >= opts.Expect) {
+			break
+		}
+		if _, ok := seen[dev.USN]; !ok {
+			report(dev)
+		}
+	}
+	if cbErr != nil {
+		return cbErr
+	}
+	if err := parent.Err(); err != nil {
+		return err
+	}
+	if len(seen) > 0 {
+		// an interface that failed is not interesting if another one worked
+		return nil
+	}
+
+	var failed []error
+	for err := range errs {
+		failed = append(failed, err)
+	}
+	if len(failed) == len(addrs) {
+		return fmt.Errorf("SSDP search failed on every interface: %w", errors.Join(failed...))
+	}
+	return nil
+}
+
+// searchIface sends an M-SEARCH, plus any retransmits, from the given
+// interface address and forwards every roku response to found until the
+// timeout or ctx is done.
+func searchIface(ctx context.Context, a ifaceAddr, opts SSDPOptions, found chan<- *Device) error {
+	log := logging.FromContext(ctx).With(zap.String("interface", a.iface.Name))
+	network, zone := "udp4", ""
+	if a.is6() {
+		network, zone = "udp6", a.iface.Name
+	}
 	ua := &net.UDPAddr{
-		IP:   raddr.IP,
+		IP:   a.addr.IP,
 		Port: 0,
-		Zone: "",
+		Zone: zone,
 	}
 
-	lc, err := net.ListenUDP("udp", ua)
+	lc, err := net.ListenUDP(network, ua)
 	if err != nil {
 		return fmt.Errorf("failed to listen: %w", err)
 	}
-	if err := lc.SetReadBuffer(1 << 10); err != nil {
+	defer lc.Close()
+	// responses tend to arrive in a burst, so leave room for plenty of them
+	if err := lc.SetReadBuffer(readBufferSize); err != nil {
 		return fmt.Errorf("failed to set read buffer: %w", err)
 	}
 	ua = lc.LocalAddr().(*net.UDPAddr)
 
-	sent, err := lc.WriteTo([]byte(ssdpBody), ssdpAddr)
-	if err != nil {
-		return fmt.Errorf("failed to send SSDP request: %w", err)
+	// closing the socket is the only way to interrupt a blocked read
+	stop := context.AfterFunc(ctx, func() { lc.Close() })
+	defer stop()
+
+	send := func() error {
+		for _, to := range a.groups() {
+			msg := searchRequest(hostHeader(to), opts.MX)
+			sent, err := lc.WriteTo(msg, to)
+			if err != nil {
+				return fmt.Errorf("failed to send SSDP request to %s: %w", to, err)
+			}
+			log.Debug("sent ssdp request", zap.Int("bytes", sent), zap.String("from", ua.String()), zap.String("to", to.String()))
+		}
+		return nil
+	}
+	if err := send(); err != nil {
+		return err
 	}
 
-	log.Debug("sent ssdp request", zap.Int("bytes", sent), zap.String("from", ua.String()), zap.String("to", ssdpAddr.String()))
+	deadline := time.Now().Add(opts.Timeout)
+	go func() {
+		gap := firstRetransmit
+		for i := 0; i < opts.Retransmits && time.Now().Add(gap).Before(deadline); i++ {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(gap):
+			}
+			if err := send(); err != nil {
+				log.Debug("failed to resend ssdp request", zap.Error(err))
+				return
+			}
+			log.Debug("resent ssdp request", zap.Int("attempt", i+1))
+			gap *= 2
+		}
+	}()
 
-	buf := make([]byte, 1<<20)
-	if err := lc.SetDeadline(time.Now().Add(2 * time.Second)); err != nil {
+	buf := make([]byte, 1<<16)
+	if err := lc.SetDeadline(deadline); err != nil {
 		return fmt.Errorf("failed to set read deadline: %w", err)
 	}
 	start := time.Now()
@@ -77,10 +305,13 @@ func SSDP(ctx context.Context, cb func(*Device) error) error {
 		cnt, addr, err := lc.ReadFromUDP(buf)
 		if os.IsTimeout(err) {
 			log.Debug("timeout waiting for SSDP responses", zap.Duration("time", time.Since(start)))
-			break
+			return nil
+		}
+		if ctx.Err() != nil {
+			return nil
 		}
 		if err != nil {
-			return fmt.Errorf("failed upp (SSDP) read: %w", err)
+			return fmt.Errorf("failed udp (SSDP) read: %w", err)
 		}
 		log.Debug("read udp bytes", zap.Int("bytes", cnt), zap.String("source", addr.String()), zap.Duration("time", time.Since(start)))
 		rokuDev, err := handleSSDPResponse(rokuDevFromHTTP, buf[:cnt])
@@ -88,34 +319,134 @@ func SSDP(ctx context.Context, cb func(*Device) error) error {
 			continue
 		}
 		if err != nil {
-			return fmt.Errorf("failed to get roku device from SSDP response: %w", err)
+			log.Debug("failed to get roku device from SSDP response", zap.String("source", addr.String()), zap.Error(err))
+			continue
+		}
+
+		if a.is6() {
+			addZone(rokuDev.Location, a.iface.Name)
 		}
 
 		log.Debug("found roku device", zap.String("USN", rokuDev.USN), zap.String("URL", rokuDev.Location.String()), zap.String("group", rokuDev.DeviceGroup))
 
-		if err := cb(rokuDev); err != nil {
-			return fmt.Errorf("SSDP callback returned error: %w", err)
+		select {
+		case found <- rokuDev:
+		case <-ctx.Done():
+			return nil
 		}
 	}
-	return nil
 }
 
-func getLocalAddr(ctx context.Context) (*net.Interface, *net.IPNet, error) {
+// searchRequest is the embedded M-SEARCH with the Host header set for the
+// target group, the MX header added, and proper CRLF line endings.
+func searchRequest(host string, mx int) []byte {
+	var sb strings.Builder
+	for _, line := range strings.Split(strings.TrimSpace(ssdpBody), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.HasPrefix(strings.ToLower(line), "host:") {
+			line = "Host: " + host
+		}
+		sb.WriteString(line)
+		sb.WriteString("\r\n")
+	}
+	fmt.Fprintf(&sb, "MX: %d\r\n\r\n", mx)
+	return []byte(sb.String())
+}
+
+// hostHeader formats a multicast group the way SSDP expects in the Host
+// header, without any zone.
+func hostHeader(to *net.UDPAddr) string {
+	return net.JoinHostPort(strings.ToUpper(to.IP.String()), strconv.Itoa(to.Port))
+}
+
+// addZone scopes a link-local IPv6 location to the interface it was found
+// on. Without the zone the address can't be reached.
+func addZone(loc *url.URL, zone string) {
+	ip, err := netip.ParseAddr(loc.Hostname())
+	if err != nil || !ip.Is6() || ip.Zone() != "" || !ip.IsLinkLocalUnicast() {
+		return
+	}
+	host := ip.WithZone(zone).String()
+	if port := loc.Port(); port != "" {
+		loc.Host = net.JoinHostPort(host, port)
+	} else {
+		loc.Host = "[" + host + "]"
+	}
+}
+
+type ifaceAddr struct {
+	iface net.Interface
+	addr  *net.IPNet
+}
+
+func (a ifaceAddr) is6() bool {
+	return a.addr.IP.To4() == nil
+}
+
+// groups are the SSDP multicast groups to search from this address.
+func (a ifaceAddr) groups() []*net.UDPAddr {
+	if !a.is6() {
+		return []*net.UDPAddr{ssdpAddr}
+	}
+	var g []*net.UDPAddr
+	for _, ip := range ssdpHosts6 {
+		g = append(g, &net.UDPAddr{IP: ip, Port: ssdpPort, Zone: a.iface.Name})
+	}
+	return g
+}
+
+// getLocalAddrs returns the first IPv4 and IPv6 address, as allowed by
+// opts.IP, of every eligible interface that passes the include/exclude
+// patterns in opts.
+func getLocalAddrs(ctx context.Context, opts SSDPOptions) ([]ifaceAddr, error) {
 	log := logging.FromContext(ctx)
 	ifaces, err := net.Interfaces()
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
+	var retval []ifaceAddr
 	for _, iface := range ifaces {
-		if ok, a, err := getIfaceAddr(ctx, &iface); ok {
-			log.Debug("using interface", zap.String("name", iface.Name), zap.String("address", a.IP.String()))
-			return &iface, a, nil
-		} else if err != nil {
-			return nil, nil, err
+		if !ifaceAllowed(iface.Name, opts) {
+			log.Debug("skipping interface", zap.String("name", iface.Name), zap.String("reason", "filtered"))
+			continue
+		}
+		if opts.IP.useIPv4() {
+			if ok, a, err := getIfaceAddr(ctx, &iface); ok {
+				log.Debug("using interface", zap.String("name", iface.Name), zap.String("address", a.IP.String()))
+				retval = append(retval, ifaceAddr{iface, a})
+			} else if err != nil {
+				return nil, err
+			}
+		}
+		if opts.IP.useIPv6() {
+			if ok, a, err := getIfaceAddr6(ctx, &iface); ok {
+				log.Debug("using interface", zap.String("name", iface.Name), zap.String("address", a.IP.String()))
+				retval = append(retval, ifaceAddr{iface, a})
+			} else if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(retval) == 0 {
+		return nil, errors.New("Failed to find interface")
+	}
+	return retval, nil
+}
+
+func ifaceAllowed(name string, opts SSDPOptions) bool {
+	match := func(patterns []string) bool {
+		for _, p := range patterns {
+			if ok, _ := path.Match(p, name); ok {
+				return true
+			}
 		}
+		return false
+	}
+	if len(opts.Interfaces) > 0 && !match(opts.Interfaces) {
+		return false
 	}
-	return nil, nil, errors.New("Failed to find interface")
+	return !match(opts.ExcludeInterfaces)
 }
 
 func getIfaceAddr(ctx context.Context, iface *net.Interface) (bool, *net.IPNet, error) {
@@ -143,6 +474,32 @@ func getIfaceAddr(ctx context.Context, iface *net.Interface) (bool, *net.IPNet,
 	return false, nil, nil
 }
 
+// getIfaceAddr6 returns the address to search from on an IPv6 multicast
+// interface, preferring the link-local one since the SSDP groups are scoped.
+func getIfaceAddr6(ctx context.Context, iface *net.Interface) (bool, *net.IPNet, error) {
+	if iface == nil || 0 == (iface.Flags&net.FlagUp) || 0 == (iface.Flags&net.FlagMulticast) || 0 != (iface.Flags&net.FlagLoopback) {
+		return false, nil, nil
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return false, nil, err
+	}
+	var found *net.IPNet
+	for _, addr := range addrs {
+		a, ok := addr.(*net.IPNet)
+		if !ok || a.IP.To4() != nil || len(a.IP) != net.IPv6len {
+			continue
+		}
+		if a.IP.IsLinkLocalUnicast() {
+			return true, a, nil
+		}
+		if found == nil {
+			found = a
+		}
+	}
+	return found != nil, found, nil
+}
+
 func parseCacheControl(cc string) (time.Duration, error) {
 	m := rxCacheControl.FindStringSubmatch(cc)
 	if len(m) < 2 {
@@ -167,22 +524,31 @@ func rokuDevFromHTTP(resp *http.Response) (*Device, error) {
 	if resp.StatusCode != 200 || resp.Header.Get("ST") != "roku:ecp" {
 		return nil, ErrNotRoku
 	}
+	return rokuDevFromHeader(resp.Header)
+}
 
-	rokuUrl, err := url.Parse(resp.Header.Get("location"))
+// rokuDevFromHeader reads the headers shared by M-SEARCH responses and NOTIFY
+// ssdp:alive announcements.
+func rokuDevFromHeader(h http.Header) (*Device, error) {
+	rokuUrl, err := url.Parse(h.Get("location"))
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse url '%s': %w", resp.Header.Get("location"), err)
+		return nil, fmt.Errorf("failed to parse url '%s': %w", h.Get("location"), err)
 	}
-	broadcastInterval, err := parseCacheControl(resp.Header.Get("Cache-Control"))
+	broadcastInterval, err := parseCacheControl(h.Get("Cache-Control"))
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse cache-control '%s': %w", resp.Header.Get("Cache-Control"), err)
+		return nil, fmt.Errorf("failed to parse cache-control '%s': %w", h.Get("Cache-Control"), err)
 	}
 
 	rokuDev := Device{
 		Location:          rokuUrl,
-		USN:               strings.TrimPrefix(resp.Header.Get("USN"), "uuid:roku:ecp:"),
-		DeviceGroup:       resp.Header.Get("device-group.roku.com"),
+		USN:               usnFromHeader(h),
+		DeviceGroup:       h.Get("device-group.roku.com"),
 		BroadcastInterval: broadcastInterval,
 	}
 
 	return &rokuDev, nil
 }
+
+func usnFromHeader(h http.Header) string {
+	return strings.TrimPrefix(h.Get("USN"), "uuid:roku:ecp:")
+}
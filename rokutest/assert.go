@@ -0,0 +1,85 @@
+package rokutest
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// AssertCalled checks that at least one request with method and path was
+// received.
+func (s *Server) AssertCalled(t testing.TB, method, path string) bool {
+	t.Helper()
+	if len(s.Find(method, path)) == 0 {
+		t.Errorf("expected %s %s, got %s", method, path, s.summary())
+		return false
+	}
+	return true
+}
+
+// AssertNotCalled checks that no request with method and path was received.
+func (s *Server) AssertNotCalled(t testing.TB, method, path string) bool {
+	t.Helper()
+	if n := len(s.Find(method, path)); n > 0 {
+		t.Errorf("expected no %s %s, got %d", method, path, n)
+		return false
+	}
+	return true
+}
+
+// AssertLaunched checks that app id was launched with at least the given
+// parameters, e.g. url.Values{"contentId": {"X"}}.
+func (s *Server) AssertLaunched(t testing.TB, id string, params url.Values) bool {
+	t.Helper()
+	reqs := s.Find(http.MethodPost, "/launch/"+id)
+	if len(reqs) == 0 {
+		t.Errorf("expected app %s to be launched, got %s", id, s.summary())
+		return false
+	}
+	for _, r := range reqs {
+		if hasParams(r.Query, params) {
+			return true
+		}
+	}
+	t.Errorf("expected app %s to be launched with %s, got %s", id, params.Encode(), reqs[len(reqs)-1].Query.Encode())
+	return false
+}
+
+// AssertKeys checks that exactly these keys were pressed, in order.
+func (s *Server) AssertKeys(t testing.TB, keys ...string) bool {
+	t.Helper()
+	got := s.Keys()
+	if !slices.Equal(got, keys) {
+		t.Errorf("expected keys %q, got %q", keys, got)
+		return false
+	}
+	return true
+}
+
+func hasParams(got, want url.Values) bool {
+	for k, vs := range want {
+		if !slices.Equal(got[k], vs) {
+			return false
+		}
+	}
+	return true
+}
+
+// summary lists the received requests for failure messages.
+func (s *Server) summary() string {
+	reqs := s.Requests()
+	if len(reqs) == 0 {
+		return "no requests"
+	}
+	var out []string
+	for _, r := range reqs {
+		line := r.Method + " " + r.Path
+		if len(r.Query) > 0 {
+			line += "?" + r.Query.Encode()
+		}
+		out = append(out, line)
+	}
+	return "[" + strings.Join(out, ", ") + "]"
+}
@@ -0,0 +1,251 @@
+// Package rokutest provides an in-process fake ECP server for tests of code
+// that talks to a Roku through the roku package.
+//
+//	srv := rokutest.NewServer(t)
+//	err := srv.Device.LaunchWith(ctx, "12", url.Values{"contentId": {"X"}})
+//	srv.AssertLaunched(t, "12", url.Values{"contentId": {"X"}})
+//
+// By default the server behaves like a device with the Apps below installed,
+// backed by an emulator.Device. Individual endpoints can be overridden with
+// Handle or Respond, slowed down with SetLatency, and made to fail with Fail.
+package rokutest
+
+import (
+	"bytes"
+	"html"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dangermike/roku_toy/emulator"
+	"github.com/dangermike/roku_toy/roku"
+)
+
+// Apps is a typical list of installed apps.
+var Apps = []roku.App{
+	{ID: "2285", Version: "6.81.0", Name: "Hulu"},
+	{ID: "12", Version: "4.2.100079005", Name: "Netflix"},
+	{ID: "13535", Version: "7.18.10", Name: "Plex - Free Movies &amp; TV"},
+	{ID: "837", Version: "2.20.110005159", Name: "YouTube"},
+	{ID: "551012", Version: "14.2.89", Name: "Apple TV"},
+	{ID: "1980", Version: "4.2.2036", Name: "Vimeo"},
+	{ID: "151908", Version: "9.3.10", Name: "The Roku Channel"},
+	{ID: "23353", Version: "5.6.1", Name: "PBS"},
+	{ID: "13", Version: "15.1.2024030812", Name: "Prime Video"},
+	{ID: "164003", Version: "2.16.306230007", Name: "Cartoon Network"},
+	{ID: "143088", Version: "3.94.3", Name: "BritBox"},
+	{ID: "14295", Version: "4.23.240318", Name: "Acorn TV"},
+	{ID: "593099", Version: "5.5.21", Name: "Peacock TV"},
+	{ID: "22297", Version: "2.11.67", Name: "Spotify Music"},
+	{ID: "23048", Version: "12.2.0", Name: "Spectrum TV"},
+	{ID: "636527", Version: "1.2.49", Name: "AMC+"},
+	{ID: "683311", Version: "10.3.17", Name: "Live TV Guide"},
+}
+
+// Request is a request the server received.
+type Request struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Header http.Header
+	Body   []byte
+	Time   time.Time
+}
+
+type failure struct {
+	method, path string
+	status       int
+	// remaining is the number of requests left to fail, negative for all.
+	remaining int
+}
+
+// Server is a fake Roku. Device is a client for it; State is the emulated
+// device behind the default responses.
+type Server struct {
+	*httptest.Server
+	Device *roku.Device
+	State  *emulator.Device
+
+	ecp       http.Handler
+	overrides *http.ServeMux
+
+	mu       sync.Mutex
+	requests []Request
+	latency  time.Duration
+	failures []*failure
+}
+
+// NewServer starts a fake device that is shut down when the test ends. cfg
+// configures the emulated state; zero or one may be given. The default has
+// Apps installed and the home screen up. App names are given the way
+// QueryApps returns them, with XML escapes, so they compare equal after a
+// round trip.
+func NewServer(t testing.TB, cfg ...emulator.DeviceConfig) *Server {
+	t.Helper()
+	var dc emulator.DeviceConfig
+	if len(cfg) > 0 {
+		dc = cfg[0]
+	}
+	if dc.USN == "" {
+		dc.USN = "TEST00000001"
+	}
+	if dc.Apps == nil {
+		dc.Apps = Apps
+	}
+	apps := make([]roku.App, len(dc.Apps))
+	for i, a := range dc.Apps {
+		a.Name = html.UnescapeString(a.Name)
+		apps[i] = a
+	}
+	dc.Apps = apps
+	s := &Server{
+		State:     emulator.NewDevice(dc, nil),
+		overrides: http.NewServeMux(),
+	}
+	s.ecp = s.State.Handler()
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+
+	loc, err := url.Parse(s.URL + "/")
+	if err != nil {
+		t.Fatalf("failed to parse test server URL: %v", err)
+	}
+	s.Device = &roku.Device{Location: loc, USN: dc.USN, DeviceGroup: dc.Group}
+	return s
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	s.mu.Lock()
+	s.requests = append(s.requests, Request{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  r.URL.Query(),
+		Header: r.Header.Clone(),
+		Body:   body,
+		Time:   time.Now(),
+	})
+	latency := s.latency
+	status := s.takeFailure(r)
+	s.mu.Unlock()
+
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if status != 0 {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	if _, pattern := s.overrides.Handler(r); pattern != "" {
+		s.overrides.ServeHTTP(w, r)
+		return
+	}
+	s.ecp.ServeHTTP(w, r)
+}
+
+// takeFailure returns the status of the first matching failure, or 0. Must
+// be called with mu held.
+func (s *Server) takeFailure(r *http.Request) int {
+	for i, f := range s.failures {
+		if f.method != "" && f.method != r.Method {
+			continue
+		}
+		if f.path != "" && f.path != r.URL.Path {
+			continue
+		}
+		if f.remaining > 0 {
+			f.remaining--
+			if f.remaining == 0 {
+				s.failures = append(s.failures[:i], s.failures[i+1:]...)
+			}
+		}
+		return f.status
+	}
+	return 0
+}
+
+// Handle overrides the response for requests matching pattern, which uses
+// http.ServeMux syntax such as "GET /query/apps" or "POST /launch/{id}".
+func (s *Server) Handle(pattern string, h http.HandlerFunc) {
+	s.overrides.HandleFunc(pattern, h)
+}
+
+// Respond makes requests matching pattern get a fixed status and body.
+func (s *Server) Respond(pattern string, status int, body string) {
+	s.Handle(pattern, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(strings.TrimSpace(body), "<") {
+			w.Header().Set("Content-Type", "text/xml; charset=\"utf-8\"")
+		}
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, body)
+	})
+}
+
+// SetLatency delays every response by d.
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// Fail makes the next n requests with the given method and path fail with
+// status. An empty method or path matches any; n < 0 fails every request
+// until Reset.
+func (s *Server) Fail(method, path string, status, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if n == 0 {
+		return
+	}
+	s.failures = append(s.failures, &failure{method: method, path: path, status: status, remaining: n})
+}
+
+// Reset clears the recorded requests, latency and injected failures. The
+// emulated state and handler overrides are kept.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = nil
+	s.latency = 0
+	s.failures = nil
+}
+
+// Requests returns every request received so far, oldest first.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Find returns the requests with the given method and path.
+func (s *Server) Find(method, path string) []Request {
+	var found []Request
+	for _, r := range s.Requests() {
+		if r.Method == method && r.Path == path {
+			found = append(found, r)
+		}
+	}
+	return found
+}
+
+// Keys returns the keys sent with keypress, in order.
+func (s *Server) Keys() []string {
+	var keys []string
+	for _, r := range s.Requests() {
+		if key, ok := strings.CutPrefix(r.Path, "/keypress/"); ok && r.Method == http.MethodPost {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
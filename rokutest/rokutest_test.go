@@ -0,0 +1,69 @@
+package rokutest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// recorder captures assertion failures instead of failing the test.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssertions(t *testing.T) {
+	ctx := context.Background()
+	srv := NewServer(t)
+	require.NoError(t, srv.Device.LaunchWith(ctx, "12", url.Values{"contentId": {"X"}}))
+	require.NoError(t, srv.Device.Keypress(ctx, "up"))
+	require.NoError(t, srv.Device.Keypress(ctx, "select"))
+
+	rec := &recorder{TB: t}
+	require.True(t, srv.AssertLaunched(rec, "12", url.Values{"contentId": {"X"}}))
+	require.True(t, srv.AssertKeys(rec, "up", "select"))
+	require.True(t, srv.AssertCalled(rec, http.MethodPost, "/launch/12"))
+	require.True(t, srv.AssertNotCalled(rec, http.MethodGet, "/query/apps"))
+	require.Empty(t, rec.errors)
+
+	require.False(t, srv.AssertLaunched(rec, "12", url.Values{"contentId": {"Y"}}))
+	require.False(t, srv.AssertLaunched(rec, "837", nil))
+	require.False(t, srv.AssertKeys(rec, "up"))
+	require.Len(t, rec.errors, 3)
+	require.Equal(t, "expected app 837 to be launched, got [POST /launch/12?contentId=X, POST /keypress/up, POST /keypress/select]", rec.errors[1])
+}
+
+func TestFail(t *testing.T) {
+	ctx := context.Background()
+	srv := NewServer(t)
+	srv.Fail(http.MethodPost, "", http.StatusForbidden, 2)
+	require.Error(t, srv.Device.Keypress(ctx, "up"))
+	require.Error(t, srv.Device.Launch(ctx, "12"))
+	require.NoError(t, srv.Device.Keypress(ctx, "up"))
+	require.Len(t, srv.Requests(), 3, "failed requests are recorded too")
+
+	srv.Reset()
+	require.Empty(t, srv.Requests())
+}
+
+func TestHandle(t *testing.T) {
+	srv := NewServer(t)
+	var launched string
+	srv.Handle("POST /launch/{id}", func(w http.ResponseWriter, r *http.Request) {
+		launched = r.PathValue("id")
+		w.WriteHeader(http.StatusNoContent)
+	})
+	require.NoError(t, srv.Device.Launch(context.Background(), "999"))
+	require.Equal(t, "999", launched)
+	require.Equal(t, "", srv.State.ActiveApp(), "overridden requests skip the emulated state")
+}
@@ -0,0 +1,186 @@
+// Package snapshot compares screenshots against stored golden images for
+// visual regression tests.
+package snapshot
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var ErrMismatch = errors.New("screenshot does not match golden image")
+
+type Options struct {
+	// Threshold is the perceptual color distance, from 0 to 1, above which a
+	// pixel counts as different. JPEG noise usually stays under 0.1.
+	Threshold float64
+	// MaxDiffRatio is the fraction of compared pixels that may differ before
+	// the images are considered a mismatch.
+	MaxDiffRatio float64
+	// Masks are regions, in image coordinates, that are not compared (clocks,
+	// carousels, etc).
+	Masks []image.Rectangle
+}
+
+func DefaultOptions() Options {
+	return Options{
+		Threshold:    0.1,
+		MaxDiffRatio: 0.001,
+	}
+}
+
+type Result struct {
+	DiffPixels  int
+	TotalPixels int
+	// Diff is a faded copy of the golden image with differing pixels in red
+	// and masked regions in gray.
+	Diff image.Image
+}
+
+func (r Result) Ratio() float64 {
+	if r.TotalPixels == 0 {
+		return 0
+	}
+	return float64(r.DiffPixels) / float64(r.TotalPixels)
+}
+
+func (r Result) String() string {
+	return fmt.Sprintf("%d of %d pixels differ (%.4f%%)", r.DiffPixels, r.TotalPixels, 100*r.Ratio())
+}
+
+// maxYIQDelta is the largest possible squared distance in YIQ space.
+const maxYIQDelta = 35215.0
+
+// Compare does a perceptual per-pixel comparison in YIQ color space, which
+// weighs brightness changes more than hue changes the way the eye does.
+// Images of different sizes never match.
+func Compare(actual, golden image.Image, opts Options) (Result, error) {
+	ab, gb := actual.Bounds(), golden.Bounds()
+	if ab.Size() != gb.Size() {
+		return Result{}, fmt.Errorf("%w: size %v does not match golden size %v", ErrMismatch, ab.Size(), gb.Size())
+	}
+
+	diff := image.NewRGBA(image.Rect(0, 0, gb.Dx(), gb.Dy()))
+	limit := opts.Threshold * opts.Threshold * maxYIQDelta
+	var res Result
+	for y := 0; y < gb.Dy(); y++ {
+		for x := 0; x < gb.Dx(); x++ {
+			pt := image.Pt(x, y)
+			gc := golden.At(gb.Min.X+x, gb.Min.Y+y)
+			if masked(opts.Masks, pt) {
+				diff.Set(x, y, color.Gray{Y: 0x80})
+				continue
+			}
+			res.TotalPixels++
+			if yiqDelta(actual.At(ab.Min.X+x, ab.Min.Y+y), gc) > limit {
+				res.DiffPixels++
+				diff.Set(x, y, color.RGBA{R: 0xff, A: 0xff})
+				continue
+			}
+			diff.Set(x, y, fade(gc))
+		}
+	}
+	res.Diff = diff
+	if res.Ratio() > opts.MaxDiffRatio {
+		return res, fmt.Errorf("%w: %s", ErrMismatch, res)
+	}
+	return res, nil
+}
+
+func masked(masks []image.Rectangle, pt image.Point) bool {
+	for _, m := range masks {
+		if pt.In(m) {
+			return true
+		}
+	}
+	return false
+}
+
+func yiq(c color.Color) (float64, float64, float64) {
+	r, g, b, _ := c.RGBA()
+	rf, gf, bf := float64(r>>8), float64(g>>8), float64(b>>8)
+	y := rf*0.29889531 + gf*0.58662247 + bf*0.11448223
+	i := rf*0.59597799 - gf*0.27417610 - bf*0.32180189
+	q := rf*0.21147017 - gf*0.52261711 + bf*0.31114694
+	return y, i, q
+}
+
+func yiqDelta(a, b color.Color) float64 {
+	y1, i1, q1 := yiq(a)
+	y2, i2, q2 := yiq(b)
+	dy, di, dq := y1-y2, i1-i2, q1-q2
+	return 0.5053*dy*dy + 0.299*di*di + 0.1957*dq*dq
+}
+
+// fade renders matching pixels as a light gray version of the original so the
+// red differences stand out.
+func fade(c color.Color) color.Color {
+	y, _, _ := yiq(c)
+	v := uint8(255 - (255-y)*0.1)
+	return color.RGBA{R: v, G: v, B: v, A: 0xff}
+}
+
+// ParseMask parses a region given as "x,y,w,h".
+func ParseMask(s string) (image.Rectangle, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return image.Rectangle{}, fmt.Errorf("mask '%s' must be x,y,w,h", s)
+	}
+	var v [4]int
+	for i, p := range parts {
+		n, err := strconv.Atoi(strings.TrimSpace(p))
+		if err != nil {
+			return image.Rectangle{}, fmt.Errorf("mask '%s' must be x,y,w,h: %w", s, err)
+		}
+		v[i] = n
+	}
+	return image.Rect(v[0], v[1], v[0]+v[2], v[1]+v[3]), nil
+}
+
+func Load(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	return img, nil
+}
+
+// Save writes img as PNG. Goldens are always stored losslessly even though
+// the device captures JPEG.
+func Save(path string, img image.Image) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, toRGBA(img)); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func toRGBA(img image.Image) *image.RGBA {
+	if r, ok := img.(*image.RGBA); ok {
+		return r
+	}
+	b := img.Bounds()
+	r := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(r, r.Bounds(), img, b.Min, draw.Src)
+	return r
+}
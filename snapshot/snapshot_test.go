@@ -0,0 +1,82 @@
+package snapshot
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func solid(w, h int, c color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+	return img
+}
+
+func TestCompare(t *testing.T) {
+	gray := color.RGBA{0x80, 0x80, 0x80, 0xff}
+	golden := solid(10, 10, gray)
+
+	t.Run("identical", func(t *testing.T) {
+		res, err := Compare(solid(10, 10, gray), golden, DefaultOptions())
+		require.NoError(t, err)
+		require.Equal(t, 0, res.DiffPixels)
+		require.Equal(t, 100, res.TotalPixels)
+	})
+
+	t.Run("noise under threshold", func(t *testing.T) {
+		res, err := Compare(solid(10, 10, color.RGBA{0x82, 0x7f, 0x80, 0xff}), golden, DefaultOptions())
+		require.NoError(t, err)
+		require.Equal(t, 0, res.DiffPixels)
+	})
+
+	t.Run("changed region", func(t *testing.T) {
+		actual := solid(10, 10, gray)
+		draw.Draw(actual, image.Rect(0, 0, 2, 2), image.NewUniform(color.White), image.Point{}, draw.Src)
+		res, err := Compare(actual, golden, DefaultOptions())
+		require.ErrorIs(t, err, ErrMismatch)
+		require.Equal(t, 4, res.DiffPixels)
+		require.Equal(t, color.RGBA{R: 0xff, A: 0xff}, res.Diff.At(1, 1))
+
+		opts := DefaultOptions()
+		opts.MaxDiffRatio = 0.05
+		_, err = Compare(actual, golden, opts)
+		require.NoError(t, err)
+
+		opts = DefaultOptions()
+		opts.Masks = []image.Rectangle{image.Rect(0, 0, 2, 2)}
+		res, err = Compare(actual, golden, opts)
+		require.NoError(t, err)
+		require.Equal(t, 96, res.TotalPixels)
+	})
+
+	t.Run("size mismatch", func(t *testing.T) {
+		_, err := Compare(solid(10, 11, gray), golden, DefaultOptions())
+		require.ErrorIs(t, err, ErrMismatch)
+	})
+}
+
+func TestParseMask(t *testing.T) {
+	r, err := ParseMask("10, 20,30,40")
+	require.NoError(t, err)
+	require.Equal(t, image.Rect(10, 20, 40, 60), r)
+
+	_, err = ParseMask("10,20,30")
+	require.Error(t, err)
+	_, err = ParseMask("a,b,c,d")
+	require.Error(t, err)
+}
+
+func TestSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "golden.png")
+	img := solid(3, 2, color.RGBA{1, 2, 3, 0xff})
+	require.NoError(t, Save(path, img))
+	loaded, err := Load(path)
+	require.NoError(t, err)
+	res, err := Compare(loaded, img, Options{})
+	require.NoError(t, err)
+	require.Equal(t, 0, res.DiffPixels)
+}
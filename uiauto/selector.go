@@ -0,0 +1,213 @@
+package uiauto
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dangermike/roku_toy/roku"
+)
+
+// Selector is a small subset of CSS selectors applied to the app-ui tree.
+//
+//	Label                    element by tag
+//	#profileName             element whose name attribute is profileName
+//	[text="Kids"]            attribute equals
+//	[text*=Kid]              attribute contains
+//	[text^=Ki]               attribute has prefix
+//	[focusable]              attribute present
+//	RowList Label[text=Kids] descendant
+type Selector struct {
+	src   string
+	parts []compound
+}
+
+type compound struct {
+	tag   string
+	attrs []attrMatch
+}
+
+type attrMatch struct {
+	name  string
+	op    string
+	value string
+}
+
+func ParseSelector(s string) (Selector, error) {
+	sel := Selector{src: s}
+	p := &selParser{s: strings.TrimSpace(s)}
+	for {
+		p.skipSpace()
+		if p.done() {
+			break
+		}
+		c, err := p.compound()
+		if err != nil {
+			return Selector{}, fmt.Errorf("invalid selector '%s': %w", s, err)
+		}
+		sel.parts = append(sel.parts, c)
+	}
+	if len(sel.parts) == 0 {
+		return Selector{}, fmt.Errorf("invalid selector '%s': empty", s)
+	}
+	return sel, nil
+}
+
+func (s Selector) String() string {
+	return s.src
+}
+
+// Matches reports whether n satisfies the selector. Descendant parts are
+// checked against the ancestors of n.
+func (s Selector) Matches(n *roku.UINode) bool {
+	if len(s.parts) == 0 || !s.parts[len(s.parts)-1].matches(n) {
+		return false
+	}
+	i := len(s.parts) - 2
+	for a := n.Parent; a != nil && i >= 0; a = a.Parent {
+		if s.parts[i].matches(a) {
+			i--
+		}
+	}
+	return i < 0
+}
+
+// Find returns every node under root that matches, in document order.
+func (s Selector) Find(root *roku.UINode) []*roku.UINode {
+	var found []*roku.UINode
+	root.Walk(func(n *roku.UINode) bool {
+		if s.Matches(n) {
+			found = append(found, n)
+		}
+		return true
+	})
+	return found
+}
+
+func (c compound) matches(n *roku.UINode) bool {
+	if c.tag != "" && c.tag != "*" && c.tag != n.Tag() {
+		return false
+	}
+	for _, am := range c.attrs {
+		v, ok := n.Attr(am.name)
+		if !ok {
+			return false
+		}
+		switch am.op {
+		case "":
+		case "=":
+			if v != am.value {
+				return false
+			}
+		case "*=":
+			if !strings.Contains(v, am.value) {
+				return false
+			}
+		case "^=":
+			if !strings.HasPrefix(v, am.value) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+type selParser struct {
+	s   string
+	pos int
+}
+
+func (p *selParser) done() bool { return p.pos >= len(p.s) }
+
+func (p *selParser) peek() byte { return p.s[p.pos] }
+
+func (p *selParser) skipSpace() {
+	for !p.done() && p.peek() == ' ' {
+		p.pos++
+	}
+}
+
+func (p *selParser) ident() string {
+	start := p.pos
+	for !p.done() {
+		c := p.peek()
+		if c == ' ' || c == '#' || c == '[' || c == ']' || c == '=' || c == '*' && p.pos > start || c == '^' {
+			break
+		}
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+func (p *selParser) compound() (compound, error) {
+	var c compound
+	c.tag = p.ident()
+	for !p.done() && p.peek() != ' ' {
+		switch p.peek() {
+		case '#':
+			p.pos++
+			name := p.ident()
+			if name == "" {
+				return c, fmt.Errorf("missing name after '#' at %d", p.pos)
+			}
+			c.attrs = append(c.attrs, attrMatch{name: "name", op: "=", value: name})
+		case '[':
+			p.pos++
+			am, err := p.attr()
+			if err != nil {
+				return c, err
+			}
+			c.attrs = append(c.attrs, am)
+		default:
+			return c, fmt.Errorf("unexpected '%c' at %d", p.peek(), p.pos)
+		}
+	}
+	if c.tag == "" && len(c.attrs) == 0 {
+		return c, fmt.Errorf("empty selector at %d", p.pos)
+	}
+	return c, nil
+}
+
+func (p *selParser) attr() (attrMatch, error) {
+	var am attrMatch
+	am.name = p.ident()
+	if am.name == "" {
+		return am, fmt.Errorf("missing attribute name at %d", p.pos)
+	}
+	if p.done() {
+		return am, fmt.Errorf("unterminated attribute at %d", p.pos)
+	}
+	switch {
+	case p.peek() == ']':
+		p.pos++
+		return am, nil
+	case strings.HasPrefix(p.s[p.pos:], "*="), strings.HasPrefix(p.s[p.pos:], "^="):
+		am.op = p.s[p.pos : p.pos+2]
+		p.pos += 2
+	case p.peek() == '=':
+		am.op = "="
+		p.pos++
+	default:
+		return am, fmt.Errorf("unexpected '%c' at %d", p.peek(), p.pos)
+	}
+
+	if !p.done() && (p.peek() == '"' || p.peek() == '\'') {
+		q := p.peek()
+		end := strings.IndexByte(p.s[p.pos+1:], q)
+		if end < 0 {
+			return am, fmt.Errorf("unterminated string at %d", p.pos)
+		}
+		am.value = p.s[p.pos+1 : p.pos+1+end]
+		p.pos += end + 2
+	} else {
+		start := p.pos
+		for !p.done() && p.peek() != ']' {
+			p.pos++
+		}
+		am.value = p.s[start:p.pos]
+	}
+	if p.done() || p.peek() != ']' {
+		return am, fmt.Errorf("unterminated attribute at %d", p.pos)
+	}
+	p.pos++
+	return am, nil
+}
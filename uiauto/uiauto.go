@@ -0,0 +1,228 @@
+// Package uiauto drives Roku channel UIs by inspecting the SceneGraph tree from
+// /query/app-ui and sending keypresses until the desired element has focus.
+package uiauto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/dangermike/roku_toy/logging"
+	"github.com/dangermike/roku_toy/roku"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrNotFound       = errors.New("no element matches selector")
+	ErrNoFocus        = errors.New("no focused element")
+	ErrNavigationLoop = errors.New("navigation is going in circles")
+	ErrTooManySteps   = errors.New("navigation took too many steps")
+)
+
+// Remote is the part of *roku.Device used by the driver.
+type Remote interface {
+	QueryAppUI(ctx context.Context) (*roku.UINode, error)
+	Keypress(ctx context.Context, key string) error
+}
+
+type Driver struct {
+	Remote Remote
+	// PollInterval is the delay between app-ui queries in WaitFor.
+	PollInterval time.Duration
+	// SettleDelay is how long to wait after a keypress for the UI to update.
+	SettleDelay time.Duration
+	// MaxSteps bounds the number of keypresses NavigateTo will send.
+	MaxSteps int
+}
+
+func New(r Remote) *Driver {
+	return &Driver{
+		Remote:       r,
+		PollInterval: 250 * time.Millisecond,
+		SettleDelay:  300 * time.Millisecond,
+		MaxSteps:     100,
+	}
+}
+
+// WaitFor polls the UI tree until an element matching selector exists or the
+// timeout passes. If ctx is cancelled first its error is returned instead of
+// ErrNotFound.
+func (d *Driver) WaitFor(parent context.Context, selector string, timeout time.Duration) (*roku.UINode, error) {
+	sel, err := ParseSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+
+	for {
+		root, err := d.Remote.QueryAppUI(ctx)
+		if err != nil && ctx.Err() == nil {
+			return nil, err
+		}
+		if root != nil {
+			if found := sel.Find(root); len(found) > 0 {
+				return found[0], nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			if err := parent.Err(); err != nil {
+				return nil, err
+			}
+			return nil, fmt.Errorf("waiting for '%s': %w", selector, ErrNotFound)
+		case <-time.After(d.PollInterval):
+		}
+	}
+}
+
+// Focused returns the innermost element that has focus.
+func (d *Driver) Focused(ctx context.Context) (*roku.UINode, error) {
+	root, err := d.Remote.QueryAppUI(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if f := focused(root); f != nil {
+		return f, nil
+	}
+	return nil, ErrNoFocus
+}
+
+// NavigateTo sends arrow keys until the focused element matches selector or is
+// inside a matching element. Keys are chosen by
+// comparing the bounds of the focused element with the target. If the same
+// element is reached again with no untried directions left the navigation is
+// abandoned with ErrNavigationLoop.
+func (d *Driver) NavigateTo(ctx context.Context, selector string) (*roku.UINode, error) {
+	log := logging.FromContext(ctx)
+	sel, err := ParseSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	tried := map[string]map[string]bool{}
+	for step := 0; step <= d.MaxSteps; step++ {
+		root, err := d.Remote.QueryAppUI(ctx)
+		if err != nil {
+			return nil, err
+		}
+		cur := focused(root)
+		if cur == nil {
+			return nil, ErrNoFocus
+		}
+		targets := sel.Find(root)
+		if len(targets) == 0 {
+			return nil, fmt.Errorf("navigating to '%s': %w", selector, ErrNotFound)
+		}
+		for _, t := range targets {
+			if isAncestor(t, cur) {
+				return cur, nil
+			}
+		}
+		if step == d.MaxSteps {
+			break
+		}
+
+		state := fingerprint(cur)
+		if tried[state] == nil {
+			tried[state] = map[string]bool{}
+		}
+		var key string
+		for _, k := range directions(cur, targets[0]) {
+			if !tried[state][k] {
+				key = k
+				break
+			}
+		}
+		if key == "" {
+			return nil, fmt.Errorf("navigating to '%s': %w", selector, ErrNavigationLoop)
+		}
+		tried[state][key] = true
+
+		log.Debug("navigating", zap.String("from", cur.Path()), zap.String("key", key))
+		if err := d.Remote.Keypress(ctx, key); err != nil {
+			return nil, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(d.SettleDelay):
+		}
+	}
+	return nil, fmt.Errorf("navigating to '%s': %w", selector, ErrTooManySteps)
+}
+
+func focused(root *roku.UINode) *roku.UINode {
+	var f *roku.UINode
+	root.Walk(func(n *roku.UINode) bool {
+		if n.IsFocused() {
+			f = n
+		}
+		return true
+	})
+	return f
+}
+
+// isAncestor reports whether a is n or one of its ancestors.
+func isAncestor(a, n *roku.UINode) bool {
+	for ; n != nil; n = n.Parent {
+		if n == a {
+			return true
+		}
+	}
+	return false
+}
+
+// fingerprint identifies a focus position. List items are recycled as they
+// scroll, so the text beneath the node is included alongside its path.
+func fingerprint(n *roku.UINode) string {
+	fp := n.Path()
+	if b, ok := n.Attr("bounds"); ok {
+		fp += b
+	}
+	n.Walk(func(c *roku.UINode) bool {
+		if t, ok := c.Attr("text"); ok {
+			fp += "|" + t
+		}
+		return true
+	})
+	return fp
+}
+
+// directions orders the arrow keys by how likely they are to move focus from
+// cur toward target.
+func directions(cur, target *roku.UINode) []string {
+	cb, ok1 := bounds(cur)
+	tb, ok2 := bounds(target)
+	if !ok1 || !ok2 {
+		return []string{"down", "right", "up", "left"}
+	}
+	dx := tb.CenterX() - cb.CenterX()
+	dy := tb.CenterY() - cb.CenterY()
+
+	h, hr := "right", "left"
+	if dx < 0 {
+		h, hr = hr, h
+	}
+	v, vr := "down", "up"
+	if dy < 0 {
+		v, vr = vr, v
+	}
+	if math.Abs(dy) >= math.Abs(dx) {
+		return []string{v, h, hr, vr}
+	}
+	return []string{h, v, vr, hr}
+}
+
+// bounds returns the bounds of n or, failing that, its nearest ancestor that
+// has them.
+func bounds(n *roku.UINode) (roku.Rect, bool) {
+	for ; n != nil; n = n.Parent {
+		if b, ok := n.Bounds(); ok {
+			return b, true
+		}
+	}
+	return roku.Rect{}, false
+}
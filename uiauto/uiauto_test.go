@@ -0,0 +1,200 @@
+package uiauto
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dangermike/roku_toy/logging"
+	"github.com/dangermike/roku_toy/roku"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func node(tag string, attrs map[string]string, children ...*roku.UINode) *roku.UINode {
+	n := &roku.UINode{XMLName: xml.Name{Local: tag}, Children: children}
+	for k, v := range attrs {
+		n.Attrs = append(n.Attrs, xml.Attr{Name: xml.Name{Local: k}, Value: v})
+	}
+	for _, c := range children {
+		c.Parent = n
+	}
+	return n
+}
+
+// grid is a fake remote with rows x cols items. Horizontal navigation wraps
+// when wrap is set.
+type grid struct {
+	rows, cols int
+	r, c       int
+	wrap       bool
+	keys       []string
+}
+
+func (g *grid) QueryAppUI(context.Context) (*roku.UINode, error) {
+	var items []*roku.UINode
+	for r := 0; r < g.rows; r++ {
+		for c := 0; c < g.cols; c++ {
+			text := fmt.Sprintf("item-%d-%d", r, c)
+			attrs := map[string]string{"name": text, "bounds": fmt.Sprintf("{%d, %d, 100, 100}", c*100, r*100)}
+			if r == g.r && c == g.c {
+				attrs["focused"] = "true"
+			}
+			items = append(items, node("Item", attrs, node("Label", map[string]string{"text": text})))
+		}
+	}
+	return node("app-ui", nil, node("Scene", map[string]string{"focused": "true"}, node("Grid", nil, items...))), nil
+}
+
+func (g *grid) Keypress(_ context.Context, key string) error {
+	g.keys = append(g.keys, key)
+	switch key {
+	case "up":
+		g.r = max(0, g.r-1)
+	case "down":
+		g.r = min(g.rows-1, g.r+1)
+	case "left":
+		if g.wrap {
+			g.c = (g.c + g.cols - 1) % g.cols
+		} else {
+			g.c = max(0, g.c-1)
+		}
+	case "right":
+		if g.wrap {
+			g.c = (g.c + 1) % g.cols
+		} else {
+			g.c = min(g.cols-1, g.c+1)
+		}
+	}
+	return nil
+}
+
+func testDriver(r Remote) *Driver {
+	d := New(r)
+	d.PollInterval = time.Millisecond
+	d.SettleDelay = 0
+	return d
+}
+
+func testContext() context.Context {
+	return logging.NewContext(context.Background(), zap.NewNop())
+}
+
+func TestSelector(t *testing.T) {
+	tree := node("app-ui", nil,
+		node("Scene", map[string]string{"name": "home"},
+			node("RowList", map[string]string{"name": "profiles"},
+				node("Label", map[string]string{"text": "Kids"}),
+				node("Label", map[string]string{"text": "Grown Ups"}),
+			),
+			node("Label", map[string]string{"text": "Kids Corner"}),
+		),
+	)
+
+	for _, test := range []struct {
+		sel string
+		exp int
+	}{
+		{"Label", 3},
+		{"#profiles", 1},
+		{"Label[text=Kids]", 1},
+		{`Label[text="Grown Ups"]`, 1},
+		{"Label[text^=Kids]", 2},
+		{"Label[text*=Up]", 1},
+		{"#profiles Label", 2},
+		{"#profiles Label[text*=Corner]", 0},
+		{"Scene [text]", 3},
+		{"*[name]", 2},
+		{"Button", 0},
+	} {
+		t.Run(test.sel, func(t *testing.T) {
+			sel, err := ParseSelector(test.sel)
+			require.NoError(t, err)
+			require.Len(t, sel.Find(tree), test.exp)
+		})
+	}
+
+	for _, bad := range []string{"", "Label[text", "Label[=x]", "#", "Label[text=\"x]"} {
+		t.Run("invalid "+bad, func(t *testing.T) {
+			_, err := ParseSelector(bad)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestNavigateTo(t *testing.T) {
+	g := &grid{rows: 3, cols: 3}
+	n, err := testDriver(g).NavigateTo(testContext(), "#item-2-1")
+	require.NoError(t, err)
+	require.Equal(t, "Item", n.Tag())
+	require.Equal(t, []string{"down", "down", "right"}, g.keys)
+}
+
+func TestNavigateToAlreadyFocused(t *testing.T) {
+	g := &grid{rows: 3, cols: 3, r: 1, c: 1}
+	_, err := testDriver(g).NavigateTo(testContext(), "#item-1-1")
+	require.NoError(t, err)
+	require.Empty(t, g.keys)
+}
+
+func TestNavigateToContainedTarget(t *testing.T) {
+	// the label is inside the focused item but never has focus itself, so
+	// being on the item does not count as reaching it
+	g := &grid{rows: 1, cols: 1}
+	_, err := testDriver(g).NavigateTo(testContext(), "Label[text=item-0-0]")
+	require.ErrorIs(t, err, ErrNavigationLoop)
+}
+
+func TestNavigateToNotFound(t *testing.T) {
+	g := &grid{rows: 3, cols: 3}
+	_, err := testDriver(g).NavigateTo(testContext(), "Label[text=nope]")
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestNavigateToLoop(t *testing.T) {
+	// focus inside the target counts as reached
+	g := &grid{rows: 1, cols: 3, wrap: true}
+	_, err := testDriver(g).NavigateTo(testContext(), "Grid")
+	require.NoError(t, err)
+
+	// the button can be seen but never focused, so every direction ends up
+	// somewhere already tried
+	_, err = testDriver(&staticTarget{grid: g, extra: "Button"}).NavigateTo(testContext(), "Button")
+	require.ErrorIs(t, err, ErrNavigationLoop)
+}
+
+func TestWaitFor(t *testing.T) {
+	g := &grid{rows: 1, cols: 1}
+	n, err := testDriver(g).WaitFor(testContext(), "Label[text=item-0-0]", time.Second)
+	require.NoError(t, err)
+	require.Equal(t, "Label", n.Tag())
+
+	_, err = testDriver(g).WaitFor(testContext(), "Label[text=nope]", 10*time.Millisecond)
+	require.ErrorIs(t, err, ErrNotFound)
+
+	ctx, cancel := context.WithCancel(testContext())
+	cancel()
+	_, err = testDriver(g).WaitFor(ctx, "Label[text=nope]", time.Second)
+	require.ErrorIs(t, err, context.Canceled)
+	require.NotErrorIs(t, err, ErrNotFound)
+}
+
+// staticTarget adds an unfocusable element outside the grid.
+type staticTarget struct {
+	*grid
+	extra string
+}
+
+func (s *staticTarget) QueryAppUI(ctx context.Context) (*roku.UINode, error) {
+	root, err := s.grid.QueryAppUI(ctx)
+	if err != nil {
+		return nil, err
+	}
+	scene := root.Children[0]
+	b := node(s.extra, map[string]string{"bounds": "{0, 500, 100, 100}"})
+	b.Parent = scene
+	scene.Children = append(scene.Children, b)
+	return root, nil
+}
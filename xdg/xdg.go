@@ -0,0 +1,42 @@
+// Package xdg locates the per-user config, cache and state directories
+// following the XDG base directory specification, on every platform.
+package xdg
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// App is the subdirectory used in each base directory.
+const App = "roku_toy"
+
+// ConfigHome is $XDG_CONFIG_HOME/roku_toy, or ~/.config/roku_toy.
+func ConfigHome() (string, error) {
+	return dir("XDG_CONFIG_HOME", ".config")
+}
+
+// CacheHome is $XDG_CACHE_HOME/roku_toy, or ~/.cache/roku_toy. Files here
+// can be deleted at any time.
+func CacheHome() (string, error) {
+	return dir("XDG_CACHE_HOME", ".cache")
+}
+
+// StateHome is $XDG_STATE_HOME/roku_toy, or ~/.local/state/roku_toy. It
+// holds data that should survive restarts but isn't configuration.
+func StateHome() (string, error) {
+	return dir("XDG_STATE_HOME", filepath.Join(".local", "state"))
+}
+
+// dir uses the base directory from env if it is set to an absolute path, as
+// the spec says relative ones must be ignored, and falls back to def under
+// the home directory.
+func dir(env, def string) (string, error) {
+	if base := os.Getenv(env); filepath.IsAbs(base) {
+		return filepath.Join(base, App), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, def, App), nil
+}
@@ -0,0 +1,28 @@
+package xdg
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirs(t *testing.T) {
+	t.Setenv("HOME", "/home/roku")
+	t.Setenv("XDG_CONFIG_HOME", "/etc/xdg-config")
+	t.Setenv("XDG_CACHE_HOME", "relative/is/ignored")
+	t.Setenv("XDG_STATE_HOME", "")
+
+	for _, test := range []struct {
+		fn  func() (string, error)
+		exp string
+	}{
+		{ConfigHome, "/etc/xdg-config/roku_toy"},
+		{CacheHome, "/home/roku/.cache/roku_toy"},
+		{StateHome, "/home/roku/.local/state/roku_toy"},
+	} {
+		dir, err := test.fn()
+		require.NoError(t, err)
+		require.Equal(t, filepath.FromSlash(test.exp), dir)
+	}
+}